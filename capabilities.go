@@ -0,0 +1,197 @@
+package curl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"github.com/plandem/curl-impersonate/presets"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Capabilities of curl binary: supported options, protocols and features
+type Capabilities struct {
+	// Version of curl, e.g. 8.1.1
+	Version string
+	// TLS library of curl, e.g. BoringSSL, NSS/3.92 or OpenSSL/3.0.17
+	TLS       string
+	Options   map[string]bool
+	Protocols map[string]bool
+	Features  map[string]bool
+}
+
+// options added by curl-impersonate, builds with BoringSSL or NSS support them even if they are missing in --help
+var impersonateOptions = map[string]bool{
+	"alps":                       true,
+	"cert-compression":           true,
+	"http2-pseudo-headers-order": true,
+	"http2-no-server-push":       true,
+	"tls-permute-extensions":     true,
+	"signature-hashes":           true,
+	"tls-session-ticket":         true,
+}
+
+var tlsLibraries = []string{"BoringSSL", "NSS", "OpenSSL", "LibreSSL", "quictls", "GnuTLS", "wolfSSL", "mbedTLS", "Schannel", "SecureTransport", "rustls", "BearSSL"}
+
+var helpOption = regexp.MustCompile(`--([a-zA-Z0-9][a-zA-Z0-9.\-]*)`)
+
+var capabilitiesCache = struct {
+	sync.Mutex
+	m map[string]*Capabilities
+}{m: make(map[string]*Capabilities)}
+
+// DetectCapabilities parses output of `curl -V` and `curl --help all` once per binary, result is cached until binary is changed
+func DetectCapabilities(binary string) (*Capabilities, error) {
+	key := binary
+	if info, err := os.Stat(binary); err == nil {
+		key = fmt.Sprintf("%s:%d:%d", binary, info.Size(), info.ModTime().UnixNano())
+	}
+
+	capabilitiesCache.Lock()
+	defer capabilitiesCache.Unlock()
+
+	if c, ok := capabilitiesCache.m[key]; ok {
+		return c, nil
+	}
+
+	version, err := exec.Command(binary, "-V").Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to detect version of curl %s: %w", binary, err)
+	}
+
+	// curl exits with error for --help of unknown category on old versions, output still has all options
+	help, _ := exec.Command(binary, "--help", "all").Output()
+
+	c := parseCapabilities(version, help)
+	capabilitiesCache.m[key] = c
+	return c, nil
+}
+
+func parseCapabilities(version []byte, help []byte) *Capabilities {
+	c := &Capabilities{
+		Options:   make(map[string]bool),
+		Protocols: make(map[string]bool),
+		Features:  make(map[string]bool),
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(version))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "curl" && c.Version == "" && len(fields) > 1:
+			c.Version = fields[1]
+			for _, field := range fields[2:] {
+				for _, lib := range tlsLibraries {
+					if field == lib || strings.HasPrefix(field, lib+"/") {
+						c.TLS = field
+					}
+				}
+			}
+		case fields[0] == "Protocols:":
+			for _, p := range fields[1:] {
+				c.Protocols[strings.ToLower(p)] = true
+			}
+		case fields[0] == "Features:":
+			for _, f := range fields[1:] {
+				c.Features[strings.ToLower(f)] = true
+			}
+		}
+	}
+
+	for _, line := range strings.Split(string(help), "\n") {
+		if m := helpOption.FindStringSubmatch(line); m != nil {
+			c.Options[m[1]] = true
+		}
+	}
+
+	return c
+}
+
+// Supports reports if curl supports long option (without leading dashes)
+func (c *Capabilities) Supports(option string) bool {
+	if c.Options[option] {
+		return true
+	}
+
+	// boolean options can be negated with no- prefix
+	if strings.HasPrefix(option, "no-") && c.Options[strings.TrimPrefix(option, "no-")] {
+		return true
+	}
+
+	return c.Build() != "" && (impersonateOptions[option] || impersonateOptions[strings.TrimPrefix(option, "no-")])
+}
+
+// HasProtocol reports if curl supports protocol, e.g. https
+func (c *Capabilities) HasProtocol(protocol string) bool {
+	return c.Protocols[strings.ToLower(protocol)]
+}
+
+// HasFeature reports if curl was built with feature, e.g. HTTP2 or HTTP3
+func (c *Capabilities) HasFeature(feature string) bool {
+	return c.Features[strings.ToLower(feature)]
+}
+
+// Build returns build of curl-impersonate by TLS library, empty build for regular curl
+func (c *Capabilities) Build() presets.Build {
+	switch {
+	case c.TLS == "BoringSSL" || strings.HasPrefix(c.TLS, "BoringSSL/"):
+		return presets.BuildChrome
+	case strings.HasPrefix(c.TLS, "NSS/"):
+		return presets.BuildFirefox
+	}
+	return ""
+}
+
+// validateCapabilities checks that binary supports all flags and TLS library required by preset
+func (curl *Curl) validateCapabilities() error {
+	c, err := DetectCapabilities(curl.binary)
+	if err != nil {
+		return err
+	}
+
+	build := c.Build()
+	if build == "" {
+		build = binaryBuild(curl.binary)
+	}
+
+	if curl.preset.Build != "" && build != "" && build != curl.preset.Build {
+		return &IncompatibleError{
+			Binary: curl.binary,
+			Reason: fmt.Sprintf("preset %s requires curl-impersonate-%s, but binary is curl-impersonate-%s", curl.preset.Name, curl.preset.Build, build),
+		}
+	}
+
+	flags := curl.mergedFlags(curl.preset)
+	for _, name := range []string{string(HTTP3), string(HTTP3Only)} {
+		// --help lists HTTP/3 options even if curl is built without HTTP/3
+		if v, ok := flags.Lookup(name); ok && v != false && !c.HasFeature("HTTP3") {
+			return &IncompatibleError{
+				Binary: curl.binary,
+				Reason: fmt.Sprintf("option --%s requires HTTP3 feature, but curl %s is built without it", name, c.Version),
+			}
+		}
+	}
+
+	for _, name := range flags.Names() {
+		if c.Supports(name) {
+			continue
+		}
+
+		reason := fmt.Sprintf("option --%s is not supported by curl %s", name, c.Version)
+		if _, ok := curl.preset.Flags.Lookup(name); ok && curl.preset.Name != "" {
+			reason = fmt.Sprintf("option --%s of preset %s is not supported by curl %s, use curl-impersonate", name, curl.preset.Name, c.Version)
+		}
+
+		return &IncompatibleError{
+			Binary: curl.binary,
+			Reason: reason,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,204 @@
+package curl
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/plandem/curl-impersonate/types"
+	"os"
+	"strings"
+)
+
+// CertType is a format of certificate or private key file
+type CertType string
+
+const (
+	PEM CertType = "PEM"
+	DER CertType = "DER"
+	// P12 is a PKCS#12 file with certificate and private key, it is supported for certificates only
+	P12 CertType = "P12"
+)
+
+// ClientCertType sets format of certificate and private key of ClientCert
+func ClientCertType(certType CertType, keyType CertType) Option {
+	return func(curl *Curl) {
+		switch certType {
+		case PEM, DER, P12:
+		default:
+			curl.fail("ClientCertType", "unknown type of certificate %q", certType)
+			return
+		}
+
+		switch keyType {
+		case "":
+		case PEM, DER:
+			curl.setFlag("key-type", string(keyType))
+		default:
+			curl.fail("ClientCertType", "unknown type of private key %q", keyType)
+			return
+		}
+
+		curl.setFlag("cert-type", string(certType))
+	}
+}
+
+// KeyPassword sets passphrase of private key or P12 file. Passphrase is never passed in arguments of process, so request with it uses ConfigStdin (or ConfigFile if stdin is used for body) instead of CommandLine.
+func KeyPassword(password string) Option {
+	return func(curl *Curl) {
+		curl.setFlag("pass", password)
+	}
+}
+
+// CAPath uses directory with certificates of CA to verify server's certificate, directory must be prepared with c_rehash (OpenSSL)
+func CAPath(dir string) Option {
+	return func(curl *Curl) {
+		info, err := os.Stat(dir)
+		if err != nil {
+			curl.fail("CAPath", "%s", err)
+			return
+		}
+		if !info.IsDir() {
+			curl.fail("CAPath", "%s is not a directory", dir)
+			return
+		}
+		curl.setFlag("capath", dir)
+	}
+}
+
+// PinnedPubKey verifies public key of server with file (PEM or DER) or with sha256 hashes, e.g. sha256//base64hash;sha256//otherhash
+func PinnedPubKey(pin string) Option {
+	return func(curl *Curl) {
+		if !strings.HasPrefix(pin, "sha256//") {
+			if err := checkFile(pin); err != nil {
+				curl.fail("PinnedPubKey", "%s", err)
+				return
+			}
+		}
+		curl.setFlag("pinnedpubkey", pin)
+	}
+}
+
+// CRLFile uses PEM file with list of revoked certificates
+func CRLFile(fileName string) Option {
+	return func(curl *Curl) {
+		if err := checkFile(fileName); err != nil {
+			curl.fail("CRLFile", "%s", err)
+			return
+		}
+		curl.setFlag("crlfile", fileName)
+	}
+}
+
+// Certificate uses in-memory certificate and private key for client authentication. They are written to temp file with 0600 permissions, that exists only while request is running.
+func Certificate(cert tls.Certificate) Option {
+	return func(curl *Curl) {
+		if len(cert.Certificate) == 0 || cert.PrivateKey == nil {
+			curl.fail("Certificate", "certificate and private key are required")
+			return
+		}
+
+		if _, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey); err != nil {
+			curl.fail("Certificate", "%s", err)
+			return
+		}
+
+		curl.clientCert = &cert
+	}
+}
+
+// RootCAs uses in-memory pool of CA certificates to verify server's certificate instead of system ones. Certificates of x509.CertPool can't be read, so curl gets certificates of PEM bundle, that must have all certificates of pool. Pool can be nil if bundle is enough. Bundle is written to temp file with 0600 permissions, that exists only while request is running.
+func RootCAs(pool *x509.CertPool, bundle []byte) Option {
+	return func(curl *Curl) {
+		var certs []*x509.Certificate
+		subjects := make(map[string]bool)
+		for rest := bundle; ; {
+			var block *pem.Block
+			if block, rest = pem.Decode(rest); block == nil {
+				break
+			}
+
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				curl.fail("RootCAs", "%s", err)
+				return
+			}
+
+			certs = append(certs, cert)
+			subjects[string(cert.RawSubject)] = true
+		}
+
+		if len(certs) == 0 {
+			curl.fail("RootCAs", "PEM bundle has no certificates")
+			return
+		}
+
+		if pool != nil {
+			// Subjects is deprecated for pools of system certificates only, they are not used as root CAs of curl
+			for _, subject := range pool.Subjects() {
+				if !subjects[string(subject)] {
+					curl.fail("RootCAs", "certificate of pool is missing in PEM bundle")
+					return
+				}
+			}
+		}
+
+		curl.rootCAs = certs
+	}
+}
+
+// withCertificates writes in-memory certificates to temp files and replaces file options of them, remove must be called after curl is finished
+func (curl *Curl) withCertificates(args []types.Arg) ([]types.Arg, func(), error) {
+	var removes []func()
+	remove := func() {
+		for _, r := range removes {
+			r()
+		}
+	}
+
+	if curl.rootCAs != nil {
+		var bundle bytes.Buffer
+		for _, cert := range curl.rootCAs {
+			_ = pem.Encode(&bundle, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		}
+
+		name, r, err := tempFile("curl-*.ca.pem", bundle.Bytes())
+		if err != nil {
+			return nil, nil, err
+		}
+		removes = append(removes, r)
+		args = replaceArgs(args, []string{"cacert", "capath"}, types.Arg{Name: "cacert", Value: name, HasValue: true})
+	}
+
+	if curl.clientCert != nil {
+		var content bytes.Buffer
+		for _, der := range curl.clientCert.Certificate {
+			_ = pem.Encode(&content, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+		}
+
+		key, err := x509.MarshalPKCS8PrivateKey(curl.clientCert.PrivateKey)
+		if err != nil {
+			remove()
+			return nil, nil, fmt.Errorf("unable to write private key: %w", err)
+		}
+		_ = pem.Encode(&content, &pem.Block{Type: "PRIVATE KEY", Bytes: key})
+
+		name, r, err := tempFile("curl-*.cert.pem", content.Bytes())
+		if err != nil {
+			remove()
+			return nil, nil, err
+		}
+		removes = append(removes, r)
+		args = replaceArgs(args, []string{"cert", "key", "cert-type", "key-type", "pass"},
+			types.Arg{Name: "cert", Value: name, HasValue: true},
+			types.Arg{Name: "cert-type", Value: string(PEM), HasValue: true},
+		)
+	}
+
+	return args, remove, nil
+}
@@ -0,0 +1,97 @@
+package curl
+
+import (
+	"bytes"
+	"fmt"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// browsers look for <meta charset> only in first 1024 bytes of document
+const metaCharsetLimit = 1024
+
+var (
+	metaCharset = regexp.MustCompile(`(?i)<meta[^>]+charset\s*=\s*["']?\s*([a-zA-Z0-9_:.\-]+)`)
+	bomUTF8     = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE  = []byte{0xFF, 0xFE}
+	bomUTF16BE  = []byte{0xFE, 0xFF}
+)
+
+// Charset returns name of charset of body: charset of Content-Type, then BOM, then <meta charset> of HTML. Empty name is returned if charset is unknown.
+func (r *Response) Charset() string {
+	return DetectCharset(r.Header.Get("Content-Type"), r.body)
+}
+
+// Text returns body decoded to UTF-8 by charset of response
+func (r *Response) Text() (string, error) {
+	return DecodeText(r.Header.Get("Content-Type"), r.body)
+}
+
+// DetectCharset returns name of charset of body with Content-Type: charset of Content-Type, then BOM, then <meta charset> of HTML. Empty name is returned if charset is unknown.
+func DetectCharset(contentType string, body []byte) string {
+	if _, params, err := mime.ParseMediaType(contentType); err == nil && params["charset"] != "" {
+		return strings.ToLower(strings.Trim(params["charset"], `"' `))
+	}
+
+	switch {
+	case bytes.HasPrefix(body, bomUTF8):
+		return "utf-8"
+	case bytes.HasPrefix(body, bomUTF16LE):
+		return "utf-16le"
+	case bytes.HasPrefix(body, bomUTF16BE):
+		return "utf-16be"
+	}
+
+	head := body
+	if len(head) > metaCharsetLimit {
+		head = head[:metaCharsetLimit]
+	}
+
+	if m := metaCharset.FindSubmatch(head); m != nil {
+		return strings.ToLower(string(m[1]))
+	}
+
+	return ""
+}
+
+// DecodeText returns body decoded to UTF-8 by charset of DetectCharset. Body without charset is returned as is if it is valid UTF-8, otherwise it is decoded as Windows-1252, same as browsers do.
+func DecodeText(contentType string, body []byte) (string, error) {
+	name := DetectCharset(contentType, body)
+	if name == "" {
+		if utf8.Valid(body) {
+			return string(body), nil
+		}
+		name = "windows-1252"
+	}
+
+	enc, err := lookupCharset(name)
+	if err != nil {
+		return "", err
+	}
+
+	// BOM is not a part of text
+	if enc == unicode.UTF8 || enc == encoding.Nop {
+		return string(bytes.TrimPrefix(body, bomUTF8)), nil
+	}
+
+	text, err := enc.NewDecoder().Bytes(body)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode body as %s: %w", name, err)
+	}
+
+	return string(bytes.TrimPrefix(text, bomUTF8)), nil
+}
+
+// lookupCharset returns encoding by label of WHATWG Encoding Standard, e.g. iso-8859-1 is decoded as windows-1252 as browsers do
+func lookupCharset(name string) (encoding.Encoding, error) {
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", name)
+	}
+	return enc, nil
+}
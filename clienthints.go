@@ -0,0 +1,134 @@
+package curl
+
+import (
+	"github.com/plandem/curl-impersonate/presets"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// hintStore remembers high-entropy client hints that origins asked for with Accept-CH, same as Chromium does
+type hintStore struct {
+	mu      sync.Mutex
+	origins map[string]map[string]bool
+}
+
+func newHintStore() *hintStore {
+	return &hintStore{
+		origins: make(map[string]map[string]bool),
+	}
+}
+
+// requested returns high-entropy hints that origin of rawURL asked for
+func (s *hintStore) requested(rawURL string) []string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hints := s.origins[origin(u)]
+	var result []string
+	for _, name := range presets.HighEntropyHints {
+		if hints[name] {
+			result = append(result, name)
+		}
+	}
+	return result
+}
+
+// update persists Accept-CH of navigation responses for each hop of request and returns true if final response requires hints that were not sent with Critical-CH
+func (s *hintStore) update(rawURL string, headers []http.Header, sent []string, navigation bool) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || !navigation {
+		return false
+	}
+
+	requestOrigin := origin(u)
+	for i, header := range headers {
+		if values, ok := header[http.CanonicalHeaderKey("Accept-CH")]; ok && isSecure(u) {
+			s.persist(origin(u), values)
+		}
+
+		location := header.Get("Location")
+		if location == "" || i == len(headers)-1 {
+			continue
+		}
+
+		next, err := u.Parse(location)
+		if err != nil {
+			return false
+		}
+		u = next
+	}
+
+	if len(headers) == 0 || origin(u) != requestOrigin {
+		return false
+	}
+
+	wasSent := make(map[string]bool)
+	for _, name := range sent {
+		wasSent[name] = true
+	}
+
+	stored := make(map[string]bool)
+	for _, name := range s.requested(rawURL) {
+		stored[name] = true
+	}
+
+	for _, name := range splitList(headers[len(headers)-1].Values("Critical-CH")) {
+		if stored[name] && !wasSent[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// persist replaces hints of origin with hints from Accept-CH
+func (s *hintStore) persist(origin string, values []string) {
+	hints := make(map[string]bool)
+	for _, name := range splitList(values) {
+		hints[name] = true
+	}
+
+	s.mu.Lock()
+	s.origins[origin] = hints
+	s.mu.Unlock()
+}
+
+func (curl *Curl) isNavigation() bool {
+	return curl.destination == "" || curl.destination == presets.Document || curl.destination == presets.Form
+}
+
+// splitList splits values of comma-separated header into lower-cased items
+func splitList(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// isSecure reports if URL is potentially trustworthy, client hints are delegated only to such origins
+func isSecure(u *url.URL) bool {
+	if u.Scheme == "https" {
+		return true
+	}
+
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
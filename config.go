@@ -0,0 +1,163 @@
+package curl
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/plandem/curl-impersonate/presets"
+	"github.com/plandem/curl-impersonate/types"
+	"io"
+	"os"
+	"strings"
+)
+
+// ArgsMode is a way to pass options to curl
+type ArgsMode int
+
+const (
+	// CommandLine passes options as arguments of process, they are visible to other users via ps or /proc
+	CommandLine ArgsMode = iota
+	// ConfigStdin writes options as config document to stdin of curl (--config -), can't be used with flags that read body from stdin
+	ConfigStdin
+	// ConfigFile writes options as config document to temp file with 0600 permissions, that is removed after request
+	ConfigFile
+)
+
+// Args sets a way to pass headers, flags and URL to curl
+func Args(mode ArgsMode) Option {
+	return func(curl *Curl) {
+		switch mode {
+		case CommandLine, ConfigStdin, ConfigFile:
+			curl.argsMode = mode
+		default:
+			curl.fail("Args", "unknown mode %d", mode)
+		}
+	}
+}
+
+// arguments returns options of curl for request: headers of client merged into order of preset, then flags
+func (curl *Curl) arguments(url string, preset presets.Preset) ([]types.Arg, error) {
+	args, err := preset.WithHeaders(curl.headers).Headers.Args(false)
+	if err != nil {
+		return nil, err
+	}
+
+	flags, err := curl.mergedFlags(preset).Args()
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, flags...)
+	args = append(args, types.Arg{Name: "url", Value: url, HasValue: true})
+	return args, nil
+}
+
+// command returns arguments of process and stdin for options in chosen mode, cleanup must be called after curl is finished
+func (curl *Curl) command(args []types.Arg) ([]string, io.Reader, func(), error) {
+	mode := curl.argsMode
+	if mode == CommandLine {
+		for _, arg := range args {
+			// passphrase is never visible in arguments of process, so config is used for it
+			if arg.Name == "pass" || arg.Name == "proxy-pass" {
+				mode = ConfigStdin
+			}
+		}
+
+		for _, arg := range args {
+			if mode == ConfigStdin && readsStdin(arg) {
+				mode = ConfigFile
+			}
+		}
+	}
+
+	if mode == CommandLine {
+		return types.CommandLine(args), nil, func() {}, nil
+	}
+
+	config, err := types.Config(args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if mode == ConfigStdin {
+		for _, arg := range args {
+			if readsStdin(arg) {
+				return nil, nil, nil, &OptionError{Option: "Args", Reason: fmt.Sprintf("--%s %s reads stdin, that is used for config, use ConfigFile instead", arg.Name, arg.Value)}
+			}
+		}
+
+		return []string{"--config", "-"}, bytes.NewReader(config), func() {}, nil
+	}
+
+	name, cleanup, err := tempFile("curl-*.conf", config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return []string{"--config", name}, nil, cleanup, nil
+}
+
+// readsStdin reports if option makes curl read stdin, e.g. --data @- or --upload-file -
+func readsStdin(arg types.Arg) bool {
+	switch arg.Name {
+	case "data", "data-ascii", "data-binary", "json", "header", "proxy-header":
+		return arg.Value == "@-"
+	case "data-urlencode", "variable":
+		// e.g. name@-
+		return strings.HasSuffix(arg.Value, "@-")
+	case "form":
+		return strings.HasSuffix(arg.Value, "=@-") || strings.HasSuffix(arg.Value, "=<-")
+	case "upload-file":
+		return arg.Value == "-" || arg.Value == "."
+	case "config":
+		return arg.Value == "-"
+	}
+	return false
+}
+
+// tempFile writes content to temp file with 0600 permissions, remove must be called when file is not needed anymore
+func tempFile(pattern string, content []byte) (string, func(), error) {
+	// CreateTemp creates file with 0600 permissions
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", nil, err
+	}
+
+	remove := func() {
+		_ = os.Remove(f.Name())
+	}
+
+	if _, err = f.Write(content); err == nil {
+		err = f.Close()
+	} else {
+		_ = f.Close()
+	}
+
+	if err != nil {
+		remove()
+		return "", nil, err
+	}
+
+	return f.Name(), remove, nil
+}
+
+// replaceArgs removes options with names and adds extra options right before URL, that stays last
+func replaceArgs(args []types.Arg, names []string, extra ...types.Arg) []types.Arg {
+	removed := make(map[string]bool)
+	for _, name := range names {
+		removed[name] = true
+	}
+
+	result := make([]types.Arg, 0, len(args)+len(extra))
+	for _, arg := range args {
+		if removed[arg.Name] {
+			continue
+		}
+
+		if arg.Name == "url" {
+			result = append(result, extra...)
+			extra = nil
+		}
+		result = append(result, arg)
+	}
+
+	return append(result, extra...)
+}
@@ -2,15 +2,17 @@ package curl
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"github.com/plandem/curl-impersonate/presets"
 	"github.com/plandem/curl-impersonate/types"
-	"io"
 	"net/http"
 	"os"
 	"os/exec"
-	"strconv"
+	"path/filepath"
 	"strings"
 )
 
@@ -19,19 +21,32 @@ type Option func(o *Curl)
 type Curl struct {
 	headers *types.Headers
 	flags   *types.Flags
-	preset  presets.Preset
-	binary  string
-	isValid bool
+	// flags of client that request was forked from
+	clientFlags []*types.Flags
+	preset      presets.Preset
+	destination presets.Destination
+	initiator   string
+	clientHints *hintStore
+	binary      string
+	argsMode    ArgsMode
+	parseMode   ParseMode
+	maxBodySize int64
+	captureTLS  bool
+	// in-memory certificates, they are written to temp files for each request
+	clientCert *tls.Certificate
+	rootCAs    []*x509.Certificate
+	resolver   *Resolver
+	isValid    bool
+	// first error of options, returned by Validate
+	err error
 }
 
 func New(options ...Option) *Curl {
 	curl := &Curl{
-		preset:  presets.Default(),
-		headers: types.NewHeaders(),
-		flags: types.NewFlags(
-			types.Flag("include", true),
-			types.Flag("silent", true),
-		),
+		preset:      presets.Default(),
+		clientHints: newHintStore(),
+		headers:     types.NewHeaders(),
+		flags:       types.NewFlags(),
 	}
 
 	curl.Set(options...)
@@ -49,21 +64,104 @@ func (curl *Curl) Set(options ...Option) {
 	}
 }
 
+// fork returns copy of curl with options applied only to that copy, so options can be used for single request
+func (curl *Curl) fork(options ...Option) *Curl {
+	c := *curl
+	c.headers = curl.headers.Clone()
+	c.clientFlags = append(curl.clientFlags[:len(curl.clientFlags):len(curl.clientFlags)], curl.flags)
+	c.flags = types.NewFlags()
+	c.Set(options...)
+	return &c
+}
+
+// defaultFlags are required to parse output of curl
+func defaultFlags() *types.Flags {
+	return types.NewFlags(
+		types.Flag("include", true),
+		types.Flag("silent", true),
+	)
+}
+
+// userFlags returns flags of client merged with flags of request
+func (curl *Curl) userFlags() *types.Flags {
+	return types.MergeFlags(append(curl.clientFlags[:len(curl.clientFlags):len(curl.clientFlags)], curl.flags)...)
+}
+
+// mergedFlags resolves default flags, flags of preset, client and request, so each flag has single value
+func (curl *Curl) mergedFlags(preset presets.Preset) *types.Flags {
+	layers := append([]*types.Flags{defaultFlags(), preset.Flags}, curl.clientFlags...)
+	return types.MergeFlags(append(layers, curl.flags)...)
+}
+
+// Header sets header of request, empty value is sent as empty header
 func Header(key string, value string) func(*Curl) {
 	return func(curl *Curl) {
+		if !types.ValidHeaderName(key) || !types.ValidHeaderValue(value) {
+			curl.fail("Header", "invalid header %q: %q", key, value)
+			return
+		}
 		curl.headers.Set(key, value)
 	}
 }
 
+// Headers sets headers of request from http.Header, e.g. headers of request made for other library. Each header replaces header with same name.
+func Headers(header http.Header) Option {
+	return func(curl *Curl) {
+		h := types.FromHTTPHeader(header)
+		for _, k := range h.Keys() {
+			for i, v := range h.Values(k) {
+				if !types.ValidHeaderName(k) || !types.ValidHeaderValue(v) {
+					curl.fail("Headers", "invalid header %q: %q", k, v)
+					return
+				}
+
+				if i == 0 {
+					curl.headers.Set(k, v)
+				} else {
+					curl.headers.Add(k, v)
+				}
+			}
+		}
+	}
+}
+
+// RemoveHeader removes header, including header of preset or default header of curl (e.g. Accept)
+func RemoveHeader(key string) Option {
+	return func(curl *Curl) {
+		if !types.ValidHeaderName(key) {
+			curl.fail("RemoveHeader", "invalid name of header %q", key)
+			return
+		}
+		curl.headers.Remove(key)
+	}
+}
+
 func Flag(name string, value interface{}) func(*Curl) {
 	return func(curl *Curl) {
 		curl.flags.Set(name, value)
+		curl.isValid = false
+	}
+}
+
+func RepeatedFlag(name string, value interface{}) func(*Curl) {
+	return func(curl *Curl) {
+		curl.flags.Add(name, value)
+		curl.isValid = false
+	}
+}
+
+// UnsetFlag removes flag, including flag of preset or default flag
+func UnsetFlag(name string) func(*Curl) {
+	return func(curl *Curl) {
+		curl.flags.Unset(name)
+		curl.isValid = false
 	}
 }
 
 func Preset(preset presets.PresetFn) func(*Curl) {
 	return func(curl *Curl) {
 		curl.preset = preset()
+		curl.isValid = false
 	}
 }
 
@@ -77,6 +175,10 @@ func Binary(fileName string) func(*Curl) {
 func (curl *Curl) Validate() error {
 	curl.isValid = false
 
+	if curl.err != nil {
+		return curl.err
+	}
+
 	if _, err := os.Stat(curl.binary); os.IsNotExist(err) {
 		if fullPath, err := exec.LookPath(curl.binary); err != nil {
 			return err
@@ -85,150 +187,252 @@ func (curl *Curl) Validate() error {
 		}
 	}
 
+	if err := curl.validateCapabilities(); err != nil {
+		return err
+	}
+
 	curl.isValid = true
 	return nil
 }
 
+// binaryBuild guesses build of curl-impersonate by name of binary (e.g. curl-impersonate-chrome, curl_ff117 or curl_safari15_5), returns empty build if it is unknown
+func binaryBuild(binary string) presets.Build {
+	name := strings.ToLower(filepath.Base(binary))
+	switch {
+	case strings.Contains(name, "chrome"), strings.Contains(name, "edge"), strings.Contains(name, "safari"):
+		return presets.BuildChrome
+	case strings.Contains(name, "-ff"), strings.Contains(name, "_ff"), strings.Contains(name, "firefox"):
+		return presets.BuildFirefox
+	}
+
+	return ""
+}
+
 func (curl *Curl) SetHeader(key string, value string) {
 	curl.headers.Set(key, value)
 }
 
+// RemoveHeader removes header, including header of preset or default header of curl
+func (curl *Curl) RemoveHeader(key string) {
+	curl.headers.Remove(key)
+}
+
 func (curl *Curl) SetFlag(name string, value interface{}) {
 	curl.flags.Set(name, value)
+	curl.isValid = false
+}
+
+func (curl *Curl) AddFlag(name string, value interface{}) {
+	curl.flags.Add(name, value)
+	curl.isValid = false
+}
+
+// UnsetFlag removes flag, including flag of preset or default flag
+func (curl *Curl) UnsetFlag(name string) {
+	curl.flags.Unset(name)
+	curl.isValid = false
+}
+
+// DelFlag removes flag that was set for client, flags of preset are kept
+func (curl *Curl) DelFlag(name string) {
+	curl.flags.Del(name)
+	curl.isValid = false
+}
+
+// Request return http.Response to make it similar to other fetching libraries, but also returns underlying []byte of content for simpler usage without extra allocation to read resp.Body. Options are applied only to this request.
+func (curl *Curl) Request(url string, options ...Option) (*http.Response, []http.Header, []byte, error) {
+	resp, err := curl.Do(context.Background(), url, options...)
+	if err != nil {
+		if resp != nil {
+			return nil, resp.headers(), nil, err
+		}
+		return nil, nil, nil, err
+	}
+
+	return resp.Response, resp.headers(), resp.Bytes(), nil
 }
 
-// Request return http.Response to make it similar to other fetching libraries, but also returns underlying []byte of content for simpler usage without extra allocation to read resp.Body
-func (curl *Curl) Request(url string) (*http.Response, []http.Header, []byte, error) {
+// Do makes request and returns response with all hops. Response is returned with HTTPError too, so headers and body of error can be used. Options are applied only to this request.
+func (curl *Curl) Do(ctx context.Context, url string, options ...Option) (*Response, error) {
+	if len(options) > 0 {
+		curl = curl.fork(options...)
+	}
+
 	if !curl.isValid {
 		if err := curl.Validate(); err != nil {
-			return nil, nil, nil, err
+			return nil, err
+		}
+	}
+
+	preset := curl.presetFor(url)
+	hints := curl.clientHints.requested(url)
+	resp, err := curl.execute(ctx, url, preset.WithClientHints(hints...))
+	if preset.ClientHints != nil && resp != nil && curl.clientHints.update(url, resp.headers(), hints, curl.isNavigation()) {
+		// retry once with hints that server marked as critical
+		resp, err = curl.execute(ctx, url, preset.WithClientHints(curl.clientHints.requested(url)...))
+		if resp != nil {
+			curl.clientHints.update(url, resp.headers(), nil, curl.isNavigation())
+		}
+	}
+
+	return resp, err
+}
+
+// execute runs curl with preset, returns response even if HTTPError is returned
+func (curl *Curl) execute(ctx context.Context, url string, preset presets.Preset) (*Response, error) {
+	args, err := curl.arguments(url, preset)
+	if err != nil {
+		return nil, err
+	}
+
+	dumpFile := ""
+	if curl.parseMode == ParseDumpHeader {
+		var removeDump func()
+		if args, dumpFile, removeDump, err = withHeaderDump(args); err != nil {
+			return nil, err
 		}
+		defer removeDump()
 	}
 
-	var args []string
+	if curl.captureTLS {
+		if args, err = withTLSCapture(args); err != nil {
+			return nil, err
+		}
+	}
 
-	args = append(args, curl.preset.Headers.Generate(false)...)
-	args = append(args, curl.headers.Generate(true)...)
-	args = append(args, curl.preset.Flags.Generate()...)
-	args = append(args, curl.flags.Generate()...)
-	args = append(args, url)
+	if curl.resolver != nil {
+		if args, err = curl.withResolve(ctx, url, args); err != nil {
+			return nil, err
+		}
+	}
+
+	args, removeCertificates, err := curl.withCertificates(args)
+	if err != nil {
+		return nil, err
+	}
+	defer removeCertificates()
 
-	cmd := exec.Command(curl.binary, args...)
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
+	cmdArgs, stdin, cleanup, err := curl.command(args)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	runCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	cmd := exec.CommandContext(runCtx, curl.binary, cmdArgs...)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	stdout := &limitWriter{limit: curl.maxBodySize, withHeaders: dumpFile == "", stop: stop}
+	if curl.maxBodySize > 0 {
+		cmd.Stdout = stdout
+	} else {
+		cmd.Stdout = &stdout.buf
+	}
 	cmd.Stderr = &stderr
 
-	err := cmd.Run()
+	err = cmd.Run()
 	if err != nil {
+		if stdout.exceeded {
+			return nil, &LimitError{Limit: curl.maxBodySize}
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) {
 			exitCode := exitErr.ExitCode()
-			return nil, nil, nil, &Error{ExitCode: exitCode}
+			return nil, &Error{ExitCode: exitCode}
 		}
 
-		return nil, nil, nil, fmt.Errorf("unexpected error executing curl: %w. stderr: %s", err, stderr.String())
+		return nil, fmt.Errorf("unexpected error executing curl: %w. stderr: %s", err, stderr.String())
 	}
 
-	// Get the full output from stdout
-	output := stdout.Bytes()
-
-	responses, lastBody, err := extractAllResponses(output)
-	if err != nil {
-		return nil, nil, nil, err
+	resp, err := parseOutput(dumpFile, stdout.buf.Bytes())
+	if resp != nil && curl.captureTLS {
+		if resp.TLSInfo = parseTLSInfo(stderr.Bytes()); resp.TLSInfo != nil {
+			resp.TLS = resp.TLSInfo.ConnectionState(url)
+		}
 	}
+	return resp, err
+}
 
-	var headers []http.Header
-	for _, rawHeaders := range responses {
-		header := parseHeaders(rawHeaders)
-		if len(header) > 0 {
-			headers = append(headers, header)
+// parseOutput returns response for output of curl, headers are read from dump if it is used
+func parseOutput(dumpFile string, output []byte) (*Response, error) {
+	if dumpFile != "" {
+		dump, err := os.ReadFile(dumpFile)
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	lastHeaders := responses[len(responses)-1]
-	statusLine := bytes.Split(lastHeaders, []byte("\n"))[0]
-	statusParts := bytes.Split(statusLine, []byte(" "))
-	if len(statusParts) < 2 {
-		return nil, nil, nil, errors.New("unable to extract status code")
+		// stdout has only body, headers of all responses are in dump
+		blocks, trailer := splitHeaderDump(dump)
+		resp, err := newResponse(blocks, output)
+		if resp != nil {
+			resp.Trailer = parseTrailer(trailer)
+		}
+		return resp, err
 	}
 
-	statusCode, err := getStatusCode(string(statusParts[1]))
+	responses, lastBody, err := extractAllResponses(output)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
-	if statusCode >= 400 {
-		return nil, nil, nil, &HTTPError{
-			StatusCode: statusCode,
-			Status:     http.StatusText(statusCode),
-		}
+
+	return newResponse(responses, lastBody)
+}
+
+// parseHop parses status and headers of block
+func parseHop(block []byte) (*Hop, error) {
+	statusLine := bytes.SplitN(block, []byte("\n"), 2)[0]
+	hop, err := parseStatusLine(string(bytes.TrimRight(statusLine, "\r")))
+	if err != nil {
+		return nil, err
 	}
 
-	return &http.Response{
-		StatusCode: statusCode,
-		Header:     headers[len(headers)-1],
-		Body:       io.NopCloser(bytes.NewReader(lastBody)),
-	}, headers, lastBody, nil
+	hop.Header = parseHeaders(block)
+	hop.RawHeader = parseRawHeaders(block)
+	hop.Raw = block
+	return hop, nil
 }
 
-// extract headers/body respecting multi-responses (e.g. proxy + redirect)
+// extract headers/body respecting multi-responses (e.g. proxy + redirect), body of final response is never scanned for headers
 func extractAllResponses(output []byte) ([][]byte, []byte, error) {
 	var headers [][]byte
-	var lastBody []byte
-	lastHeaderIndex := -1
-
-	// Track the current position in the original buffer
 	pos := 0
-
-	for {
-		// Find the next "\r\n\r\n" delimiter
+	for bytes.HasPrefix(output[pos:], []byte("HTTP/")) {
 		end := bytes.Index(output[pos:], []byte("\r\n\r\n"))
 		if end == -1 {
 			break
 		}
 
-		// Extract the current block
 		block := output[pos : pos+end]
-
-		// Check if the block starts with "HTTP/"
-		if bytes.HasPrefix(block, []byte("HTTP/")) && len(bytes.TrimSpace(block)) > len("HTTP/") {
-			headers = append(headers, block)
-			lastHeaderIndex = len(headers) - 1
+		if len(bytes.TrimSpace(block)) <= len("HTTP/") {
+			break
 		}
 
-		// Move the position past the current block and the delimiter
+		headers = append(headers, block)
 		pos += end + len("\r\n\r\n")
-	}
 
-	// If a last valid HTTP response block was found, capture the body content
-	if lastHeaderIndex != -1 {
-		lastBody = output[pos:]
+		if !followedByResponse(block, output[pos:]) {
+			break
+		}
 	}
 
 	if len(headers) == 0 {
 		return nil, nil, errors.New("unable to extract valid HTTP responses")
 	}
 
-	return headers, lastBody, nil
-}
-
-func getStatusCode(statusCode string) (int, error) {
-	code, err := strconv.Atoi(statusCode)
-	if err != nil {
-		return 0, fmt.Errorf("invalid status code format: %s", statusCode)
-	}
-	return code, nil
+	return headers, output[pos:], nil
 }
 
 func parseHeaders(headers []byte) http.Header {
 	result := make(http.Header)
-	lines := bytes.Split(headers, []byte("\r\n"))
-	for _, line := range lines {
-		if bytes.Contains(line, []byte(":")) {
-			parts := bytes.SplitN(line, []byte(":"), 2)
-			key := strings.TrimSpace(string(parts[0]))
-			value := strings.TrimSpace(string(parts[1]))
-			result.Add(key, value)
-		}
+	for _, field := range headerFields(headers) {
+		result.Add(field[0], field[1])
 	}
 	return result
 }
@@ -2,8 +2,39 @@ package curl
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
+	"github.com/plandem/curl-impersonate/presets"
+	"github.com/plandem/curl-impersonate/types"
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCurlLogic(t *testing.T) {
@@ -166,3 +197,1317 @@ func TestExtractAllResponses_NoValidHeaders(t *testing.T) {
 		t.Errorf("Expected error '%s', got '%s'", expectedError, err.Error())
 	}
 }
+
+// fakeCurl creates script that prints version and help as curl does
+func fakeCurl(t *testing.T, name string, version string, options ...string) string {
+	binary := filepath.Join(t.TempDir(), name)
+	script := "#!/bin/sh\nif [ \"$1\" = \"-V\" ]; then\n  echo '" + version + "'\n  echo 'Protocols: http https'\n  echo 'Features: HTTP2 SSL'\nelse\n"
+	for _, option := range options {
+		script += "  echo '     --" + option + " <arg> Description'\n"
+	}
+	script += "fi\n"
+
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return binary
+}
+
+func TestValidateBuild(t *testing.T) {
+	binary := fakeCurl(t, "curl-impersonate-chrome", "curl 8.1.1 (x86_64-pc-linux-gnu) libcurl/8.1.1 BoringSSL zlib/1.2.11", "include", "silent", "ciphers", "http2", "compressed", "tlsv1.2", "alps", "cert-compression", "http2-no-server-push", "tls-permute-extensions")
+
+	c := New(Binary(binary), Preset(presets.Chrome116))
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected chrome preset to be valid for chrome build, got: %v", err)
+	}
+
+	c.Set(Preset(presets.Firefox117))
+	if err := c.Validate(); !IsIncompatibleError(err) {
+		t.Errorf("Expected an IncompatibleError for firefox preset and chrome build, got: %v", err)
+	}
+
+	// build is detected by TLS library even if name of binary is unknown
+	binary = fakeCurl(t, "curl", "curl 8.1.1 (x86_64-pc-linux-gnu) libcurl/8.1.1 NSS/3.92 zlib/1.2.11", "include", "silent")
+	c = New(Binary(binary), Preset(presets.Chrome116))
+	if err := c.Validate(); !IsIncompatibleError(err) {
+		t.Errorf("Expected an IncompatibleError for chrome preset and firefox build, got: %v", err)
+	}
+}
+
+func TestValidateCapabilities(t *testing.T) {
+	binary := fakeCurl(t, "curl", "curl 7.88.1 (x86_64-pc-linux-gnu) libcurl/7.88.1 OpenSSL/3.0.17 zlib/1.2.13", "include", "silent", "ciphers", "http2", "compressed", "tlsv1.2", "max-time", "keepalive")
+
+	c := New(Binary(binary), Flag("max-time", 10), Flag("no-keepalive", true))
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected supported options to be valid, got: %v", err)
+	}
+
+	c.Set(Preset(presets.Chrome116))
+	err := c.Validate()
+	if !IsIncompatibleError(err) {
+		t.Fatalf("Expected an IncompatibleError for preset with options of curl-impersonate, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "--alps of preset chrome116") {
+		t.Errorf("Expected error to explain unsupported option, got: %v", err)
+	}
+
+	caps, _ := DetectCapabilities(binary)
+	if caps.Version != "7.88.1" || caps.TLS != "OpenSSL/3.0.17" || !caps.HasFeature("http2") || !caps.HasProtocol("HTTPS") || caps.Build() != "" {
+		t.Errorf("Unexpected capabilities: %+v", caps)
+	}
+}
+
+func TestInitiatorFor(t *testing.T) {
+	tests := []struct {
+		dest     presets.Destination
+		page     string
+		target   string
+		safe     bool
+		expected presets.Initiator
+	}{
+		{presets.Document, "", "https://example.com/", true, presets.Initiator{}},
+		{presets.Fetch, "", "https://example.com/api", true, presets.Initiator{Site: "same-origin", Referer: "https://example.com/"}},
+		{presets.Fetch, "https://example.com/page#top", "https://example.com/api", false, presets.Initiator{Site: "same-origin", Origin: "https://example.com", Referer: "https://example.com/page"}},
+		{presets.Fetch, "https://www.example.com/page", "https://api.example.com/v1", true, presets.Initiator{Site: "same-site", Origin: "https://www.example.com", Referer: "https://www.example.com/"}},
+		{presets.Image, "https://example.com/page", "https://cdn.example.net/a.png", true, presets.Initiator{Site: "cross-site", Referer: "https://example.com/"}},
+		{presets.Form, "https://example.com/login", "http://example.com/submit", false, presets.Initiator{Site: "cross-site", Origin: "https://example.com"}},
+		{presets.Fetch, "https://a.co.uk/page", "https://b.co.uk/v1", true, presets.Initiator{Site: "cross-site", Origin: "https://a.co.uk", Referer: "https://a.co.uk/"}},
+		{presets.Fetch, "https://www.shop.co.uk/page", "https://api.shop.co.uk/v1", true, presets.Initiator{Site: "same-site", Origin: "https://www.shop.co.uk", Referer: "https://www.shop.co.uk/"}},
+		{presets.Fetch, "https://a.github.io/page", "https://b.github.io/v1", true, presets.Initiator{Site: "cross-site", Origin: "https://a.github.io", Referer: "https://a.github.io/"}},
+	}
+
+	for _, test := range tests {
+		if got := initiatorFor(test.dest, test.page, test.target, test.safe); got != test.expected {
+			t.Errorf("Unexpected initiator of %s from %q to %s\nExpected: %+v\nGot:      %+v", test.dest, test.page, test.target, test.expected, got)
+		}
+	}
+}
+
+func TestClientHintsStore(t *testing.T) {
+	store := newHintStore()
+	page := "https://example.com/page"
+
+	response := http.Header{}
+	response.Set("Accept-CH", "Sec-CH-UA-Full-Version-List, Sec-CH-UA-Model, Sec-CH-Unknown")
+	response.Set("Critical-CH", "Sec-CH-UA-Full-Version-List")
+
+	if !store.update(page, []http.Header{response}, nil, true) {
+		t.Errorf("Expected retry for critical hint that was not sent")
+	}
+
+	expected := []string{"sec-ch-ua-full-version-list", "sec-ch-ua-model"}
+	hints := store.requested("https://example.com/other")
+	if !reflect.DeepEqual(hints, expected) {
+		t.Errorf("Expected hints %v for origin, got %v", expected, hints)
+	}
+
+	if store.update(page, []http.Header{response}, hints, true) {
+		t.Errorf("Expected no retry when critical hints were sent")
+	}
+
+	if hints := store.requested("https://example.org/"); len(hints) != 0 {
+		t.Errorf("Expected no hints for another origin, got %v", hints)
+	}
+
+	// insecure origins and subresources are ignored
+	store.update("http://example.net/", []http.Header{response}, nil, true)
+	store.update("https://example.org/", []http.Header{response}, nil, false)
+	if len(store.requested("http://example.net/")) != 0 || len(store.requested("https://example.org/")) != 0 {
+		t.Errorf("Expected Accept-CH to be ignored for insecure origins and subresources")
+	}
+
+	// hints are persisted for origin of hop that returned them
+	redirect := http.Header{}
+	redirect.Set("Location", "https://www.example.net/")
+	store.update("https://example.net/", []http.Header{redirect, response}, nil, true)
+	if len(store.requested("https://www.example.net/")) != 2 || len(store.requested("https://example.net/")) != 0 {
+		t.Errorf("Expected hints to be persisted for origin of redirected hop")
+	}
+
+	preset := presets.Chrome116().WithClientHints(hints...)
+	keys := preset.Keys()
+	if keys[3] != "sec-ch-ua-full-version-list" || keys[4] != "sec-ch-ua-model" {
+		t.Errorf("Expected high-entropy hints after low-entropy ones, got %v", keys)
+	}
+}
+
+func TestFlagLayers(t *testing.T) {
+	c := New(
+		Preset(presets.Chrome116),
+		Flag("tlsv1.3", true),
+		UnsetFlag("silent"),
+	)
+	request := c.fork(Flag("http1.1", true), UnsetFlag("alps"))
+
+	args := request.mergedFlags(c.preset).Generate()
+
+	count := make(map[string]int)
+	for _, arg := range args {
+		count[arg]++
+	}
+
+	for _, arg := range []string{"--include", "--tlsv1.3", "--http1.1", "--compressed"} {
+		if count[arg] != 1 {
+			t.Errorf("Expected single %s, got %d in %q", arg, count[arg], args)
+		}
+	}
+	for _, arg := range []string{"--silent", "--tlsv1.2", "--http2", "--alps"} {
+		if count[arg] != 0 {
+			t.Errorf("Expected %s to be removed, got %q", arg, args)
+		}
+	}
+
+	if args := c.mergedFlags(c.preset).Generate(); len(args) == 0 || args[len(args)-1] != "--tlsv1.3" {
+		t.Errorf("Expected options of request to not change client, got %q", args)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	c := New(
+		Timeout(1500*time.Millisecond),
+		ConnectTimeout(2*time.Second),
+		FollowRedirects(true),
+		MaxRedirects(3),
+		Resolve("example.com", 443, "127.0.0.1"),
+		Resolve("example.com", 80, "::1"),
+		ConnectTo("", 0, "2001:db8::1", 8443),
+		HTTPVersion(HTTP2),
+		HTTPVersion(HTTP11),
+		IPv4Only(),
+		LimitRate(1024),
+		MaxFileSize(1<<20),
+	)
+
+	args := c.mergedFlags(c.preset).Generate()
+
+	expected := []string{
+		"--include",
+		"--silent",
+		"--max-time", "1.5",
+		"--connect-timeout", "2",
+		"--location",
+		"--max-redirs", "3",
+		"--resolve", "example.com:443:127.0.0.1",
+		"--resolve", "example.com:80:[::1]",
+		"--connect-to", "::[2001:db8::1]:8443",
+		"--http1.1",
+		"--ipv4",
+		"--limit-rate", "1024",
+		"--max-filesize", "1048576",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Unexpected arguments\nExpected: %q\nGot:      %q", expected, args)
+	}
+
+	for _, option := range []Option{
+		Timeout(-time.Second),
+		Resolve("example.com", 70000, "127.0.0.1"),
+		Resolve("example.com", 443, "localhost"),
+		CACert(filepath.Join(t.TempDir(), "missing.pem")),
+		HTTPVersion("http4"),
+		Interface(""),
+	} {
+		if err := New(option).Validate(); !IsOptionError(err) {
+			t.Errorf("Expected an OptionError, got: %v", err)
+		}
+	}
+}
+
+func TestArgsModes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.Header.Write(w)
+		_, _ = io.WriteString(w, r.URL.RawQuery)
+	}))
+	defer server.Close()
+
+	target := server.URL + "/?q=a%20b&secret=token"
+	var expected []byte
+	for _, mode := range []ArgsMode{CommandLine, ConfigStdin, ConfigFile} {
+		c := New(
+			Args(mode),
+			Header("Authorization", `Bearer "secret" \ token`),
+			Flag("user-agent", "agent with spaces\tand tab"),
+			RepeatedFlag("cookie", "a=1; b=2"),
+		)
+
+		_, _, body, err := c.Request(target)
+		if err != nil {
+			t.Fatalf("Unexpected error for mode %d: %v", mode, err)
+		}
+
+		if expected == nil {
+			expected = body
+		} else if !bytes.Equal(body, expected) {
+			t.Errorf("Response for mode %d differs\nExpected: %q\nGot:      %q", mode, expected, body)
+		}
+
+		args, err := c.arguments(target, c.preset)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		cmdArgs, _, cleanup, err := c.command(args)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if mode != CommandLine && strings.Contains(strings.Join(cmdArgs, " "), "secret") {
+			t.Errorf("Expected no secrets in arguments of process for mode %d, got: %q", mode, cmdArgs)
+		}
+
+		if mode == ConfigFile {
+			info, err := os.Stat(cmdArgs[1])
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if info.Mode().Perm() != 0600 {
+				t.Errorf("Expected 0600 permissions of config, got: %v", info.Mode().Perm())
+			}
+
+			cleanup()
+			if _, err := os.Stat(cmdArgs[1]); !os.IsNotExist(err) {
+				t.Errorf("Expected config to be removed, got: %v", err)
+			}
+		}
+	}
+
+	for _, option := range []Option{
+		Flag("data-binary", "@-"),
+		Flag("upload-file", "-"),
+		Flag("data-urlencode", "name@-"),
+		Flag("form", "file=@-"),
+	} {
+		_, err := New(Args(ConfigStdin), option).Do(context.Background(), target)
+		if !IsOptionError(err) || !strings.Contains(err.Error(), "reads stdin") {
+			t.Errorf("Expected an OptionError for flag that reads stdin, got: %v", err)
+		}
+	}
+
+	if _, err := New(Args(ConfigStdin), Flag("data-binary", "a=1")).Do(context.Background(), target); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	if !bytes.Contains(expected, []byte(`Bearer "secret" \ token`)) || !bytes.Contains(expected, []byte("agent with spaces\tand tab")) {
+		t.Errorf("Unexpected response: %q", expected)
+	}
+}
+
+// rawServer accepts single connection and returns bytes of request headers as they were received
+func rawServer(t *testing.T, response string) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			received <- ""
+			return
+		}
+		defer conn.Close()
+
+		var request []byte
+		buf := make([]byte, 4096)
+		for !bytes.Contains(request, []byte("\r\n\r\n")) {
+			n, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			request = append(request, buf[:n]...)
+		}
+
+		_, _ = io.WriteString(conn, response)
+		received <- string(request)
+	}()
+
+	return "http://" + ln.Addr().String() + "/", received
+}
+
+func TestHeadersOnWire(t *testing.T) {
+	preset := func() presets.Preset {
+		return presets.Preset{
+			Headers: types.NewHeaders(
+				types.Header("X-Preset", "preset value"),
+				types.RemoveHeader("Accept"),
+			),
+			Flags: types.NewFlags(),
+		}
+	}
+
+	target, received := rawServer(t, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nok")
+	c := New(
+		Preset(preset),
+		Header("X-Quoted", `'single' "double"`),
+		Header("X-Empty", ""),
+		RemoveHeader("User-Agent"),
+	)
+
+	if _, _, body, err := c.Request(target); err != nil || string(body) != "ok" {
+		t.Fatalf("Unexpected response %q: %v", body, err)
+	}
+
+	request := <-received
+	for _, expected := range []string{
+		"\r\nX-Preset: preset value\r\n",
+		"\r\nX-Quoted: 'single' \"double\"\r\n",
+		"\r\nX-Empty:\r\n",
+	} {
+		if !strings.Contains(request, expected) {
+			t.Errorf("Expected %q in request:\n%s", expected, request)
+		}
+	}
+
+	for _, unexpected := range []string{"'X-", "Accept:", "User-Agent:"} {
+		if strings.Contains(request, unexpected) {
+			t.Errorf("Unexpected %q in request:\n%s", unexpected, request)
+		}
+	}
+}
+
+func TestHeaderInjection(t *testing.T) {
+	for _, option := range []Option{
+		Header("X-Test", "value\r\nX-Injected: 1"),
+		Header("X-Test", "value\nX-Injected: 1"),
+		Header("X-Test", "value\x00"),
+		Header("X-Test: 1\r\nX-Injected", "1"),
+		Header("", "value"),
+		RemoveHeader("Bad Name"),
+	} {
+		if err := New(option).Validate(); !IsOptionError(err) {
+			t.Errorf("Expected an OptionError, got: %v", err)
+		}
+	}
+
+	// headers of preset are checked before curl is started
+	c := New(Preset(func() presets.Preset {
+		return presets.Preset{Headers: types.NewHeaders(types.Header("X-Test", "a\r\nb")), Flags: types.NewFlags()}
+	}))
+	if _, err := c.arguments("http://localhost/", c.preset); err == nil {
+		t.Errorf("Expected error for invalid header of preset")
+	}
+}
+
+func TestRawHeaders(t *testing.T) {
+	target, _ := rawServer(t, "HTTP/1.1 200 OK\r\n"+
+		"x-cache: HIT\r\n"+
+		"Server: test\r\n"+
+		"Set-Cookie: a=1\r\n"+
+		"X-Served-By: cache-1\r\n"+
+		"set-cookie: b=2\r\n"+
+		"X-Folded: first\r\n"+
+		" \tsecond\r\n"+
+		"X-Spaces:  padded \r\n"+
+		"Content-Length: 2\r\n"+
+		"Connection: close\r\n\r\nok")
+
+	c := New()
+
+	resp, err := c.Do(context.Background(), target)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var got []string
+	resp.RawHeader().Range(func(k string, v string) bool {
+		got = append(got, k+": "+v)
+		return true
+	})
+
+	expected := []string{"x-cache: HIT", "Server: test", "Set-Cookie: a=1", "X-Served-By: cache-1", "set-cookie: b=2", "X-Folded: first second", "X-Spaces: padded", "Content-Length: 2", "Connection: close"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected raw headers\nExpected: %q\nGot:      %q", expected, got)
+	}
+
+	if raw := string(resp.Hops[0].Raw); !strings.Contains(raw, "X-Folded: first\r\n \tsecond\r\nX-Spaces:  padded \r\n") {
+		t.Errorf("Expected raw block as it was received, got: %q", raw)
+	}
+
+	if len(resp.Hops) != 1 || resp.Header.Get("X-Cache") != "HIT" || len(resp.Header.Values("Set-Cookie")) != 2 || string(resp.Bytes()) != "ok" {
+		t.Errorf("Unexpected response: %v %q", resp.Header, resp.Bytes())
+	}
+}
+
+func TestParseStatusLine(t *testing.T) {
+	tests := []struct {
+		line       string
+		status     string
+		code       int
+		proto      string
+		major      int
+		minor      int
+		shouldFail bool
+	}{
+		{line: "HTTP/1.1 200 OK", status: "200 OK", code: 200, proto: "HTTP/1.1", major: 1, minor: 1},
+		{line: "HTTP/1.0 200 Connection established", status: "200 Connection established", code: 200, proto: "HTTP/1.0", major: 1},
+		{line: "HTTP/2 404", status: "404 Not Found", code: 404, proto: "HTTP/2", major: 2},
+		{line: "HTTP/3 200 ", status: "200 OK", code: 200, proto: "HTTP/3", major: 3},
+		{line: "HTTP/1.1 599 Network Connect Timeout: Upstream", status: "599 Network Connect Timeout: Upstream", code: 599, proto: "HTTP/1.1", major: 1, minor: 1},
+		{line: "HTTP/1.1 799", status: "799", code: 799, proto: "HTTP/1.1", major: 1, minor: 1},
+		{line: "HTTP/1.1", shouldFail: true},
+		{line: "HTTP/1.1 20 OK", shouldFail: true},
+		{line: "HTTP/1.1 abc OK", shouldFail: true},
+		{line: "HTTP/x 200 OK", shouldFail: true},
+		{line: "ICY 200 OK", shouldFail: true},
+		{line: "", shouldFail: true},
+	}
+
+	for _, test := range tests {
+		hop, err := parseStatusLine(test.line)
+		if test.shouldFail {
+			if err == nil || !strings.Contains(err.Error(), "malformed status line") {
+				t.Errorf("Expected descriptive error for %q, got: %v", test.line, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.line, err)
+			continue
+		}
+
+		if hop.Status != test.status || hop.StatusCode != test.code || hop.Proto != test.proto || hop.ProtoMajor != test.major || hop.ProtoMinor != test.minor {
+			t.Errorf("Unexpected status of %q: %+v", test.line, hop)
+		}
+	}
+}
+
+func TestHopStatuses(t *testing.T) {
+	target, _ := rawServer(t, "HTTP/1.1 200 Everything Is Fine: Really\r\n"+
+		"Content-Length: 2\r\n"+
+		"Connection: close\r\n\r\nok")
+
+	c := New()
+
+	resp, err := c.Do(context.Background(), target)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if resp.Status != "200 Everything Is Fine: Really" || resp.Proto != "HTTP/1.1" || !resp.ProtoAtLeast(1, 1) {
+		t.Errorf("Unexpected status: %s %s", resp.Proto, resp.Status)
+	}
+
+	if _, ok := resp.Header["Everything Is Fine"]; ok {
+		t.Errorf("Expected status line to be skipped by headers: %v", resp.Header)
+	}
+
+	if hop := resp.Hops[0]; hop.Status != resp.Status || hop.Proto != resp.Proto {
+		t.Errorf("Unexpected hop: %+v", hop)
+	}
+
+	for line, expected := range map[string][2]string{
+		"HTTP/1.1 520 Web Server Returned an Unknown Error": {"HTTP Error. Web Server Returned an Unknown Error (520)", "Web Server Returned an Unknown Error"},
+		"HTTP/1.1 404 NOT FOUND":                            {"HTTP Error. Not Found (404)", "NOT FOUND"},
+		"HTTP/1.1 404":                                      {"HTTP Error. Not Found (404)", "Not Found"},
+		"HTTP/1.1 499":                                      {"HTTP Error. Unknown Status (499)", ""},
+	} {
+		_, err := newResponse([][]byte{[]byte(line + "\r\n")}, nil)
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) || err.Error() != expected[0] || httpErr.Reason != expected[1] {
+			t.Errorf("Expected %q with reason %q for %q, got: %#v", expected[0], expected[1], line, err)
+		}
+	}
+}
+
+func TestInformationalResponses(t *testing.T) {
+	target, _ := rawServer(t, "HTTP/1.1 100 Continue\r\n\r\n"+
+		"HTTP/1.1 103 Early Hints\r\n"+
+		"Link: </style.css>; rel=preload; as=style\r\n"+
+		"Link: </script.js>; rel=preload; as=script\r\n\r\n"+
+		"HTTP/1.1 200 OK\r\n"+
+		"Content-Length: 2\r\n"+
+		"Connection: close\r\n\r\nok")
+
+	c := New()
+
+	resp, err := c.Do(context.Background(), target)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if resp.StatusCode != 200 || len(resp.Hops) != 1 || len(resp.Informational) != 2 || string(resp.Bytes()) != "ok" {
+		t.Errorf("Unexpected response: %d, hops %d, informational %d", resp.StatusCode, len(resp.Hops), len(resp.Informational))
+	}
+
+	expected := []string{"</style.css>; rel=preload; as=style", "</script.js>; rel=preload; as=script"}
+	if got := resp.EarlyHints(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected early hints: %q", got)
+	}
+
+	if resp.Header.Get("Link") != "" {
+		t.Errorf("Expected no Link in final response, got: %v", resp.Header)
+	}
+}
+
+func TestParseDumpHeader(t *testing.T) {
+	body := "start\r\n\r\nHTTP/1.1 500 Fake\r\nX-Fake: 1\r\n\r\nend"
+	target, _ := rawServer(t, "HTTP/1.0 200 OK\n"+
+		"X-Test: 1\n"+
+		"Content-Length: "+strconv.Itoa(len(body))+"\n\n"+body)
+
+	c := New(
+		Parse(ParseDumpHeader),
+	)
+
+	resp, err := c.Do(context.Background(), target)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(resp.Bytes()) != body {
+		t.Errorf("Unexpected body\nExpected: %q\nGot:      %q", body, resp.Bytes())
+	}
+
+	if resp.StatusCode != 200 || resp.Proto != "HTTP/1.0" || len(resp.Hops) != 1 || resp.Header.Get("X-Test") != "1" || resp.Header.Get("X-Fake") != "" {
+		t.Errorf("Unexpected response: %s %s %v", resp.Proto, resp.Status, resp.Header)
+	}
+}
+
+func FuzzSplitHeaderDump(f *testing.F) {
+	f.Add([]byte("HTTP/1.1 200 OK\r\nA: 1\r\n\r\n"), []byte("body"))
+	f.Add([]byte("HTTP/1.0 200 OK\nA: 1\n\n"), []byte("\r\n\r\nHTTP/1.1 200 OK\r\n\r\n"))
+	f.Add([]byte("HTTP/1.1 103 Early Hints\r\nLink: </a>\r\n\r\nHTTP/2 200\r\n\r\n"), []byte("HTTP/2 404\r\n\r\n"))
+	f.Add([]byte("HTTP/1.1 301 Moved\r\nLocation: /\r\n\r\nHTTP/1.1 200 OK\r\n\r\nGrpc-Status: 0\r\n\r\n"), []byte{})
+	f.Add([]byte("HTTP/1.1 200 OK\r\nTrailer: Grpc-Status\r\n\r\n"), []byte("Grpc-Status: 0\r\n"))
+	f.Add([]byte("\n\n\nHTTP/\n\n"), []byte("\x00"))
+
+	dumpFile := filepath.Join(f.TempDir(), "headers")
+	f.Fuzz(func(t *testing.T, dump []byte, body []byte) {
+		blocks, trailer := splitHeaderDump(dump)
+		if bytes.Contains(trailer, []byte("\r\n\r\n")) {
+			t.Fatalf("Unexpected trailer %q", trailer)
+		}
+
+		for _, block := range blocks {
+			if !bytes.HasPrefix(block, []byte("HTTP/")) || bytes.Contains(block, []byte("\r\n\r\n")) {
+				t.Fatalf("Unexpected block %q", block)
+			}
+		}
+
+		if err := os.WriteFile(dumpFile, dump, 0600); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// body is never scanned for headers or trailer
+		resp, err := parseOutput(dumpFile, body)
+		if resp == nil {
+			return
+		}
+
+		content, _ := io.ReadAll(resp.Body)
+		if !bytes.Equal(resp.Bytes(), body) || !bytes.Equal(content, body) {
+			t.Fatalf("Body was changed: %q != %q (%v)", resp.Bytes(), body, err)
+		}
+	})
+}
+
+// includeOutput returns output of curl with --include for headers of responses and body, false is returned if headers are not written by curl this way
+func includeOutput(headers []byte, body []byte) ([]byte, []byte, bool) {
+	// headers are written by curl with CRLF and blank lines between responses
+	blocks, _ := splitHeaderDump(headers)
+	if len(blocks) == 0 || !bytes.Equal(headers, bytes.Join(blocks, []byte("\r\n\r\n"))) {
+		return nil, nil, false
+	}
+
+	// responses before final one are followed by other response, e.g. redirects
+	for i, block := range blocks[:len(blocks)-1] {
+		if _, err := parseHop(block); err != nil || !followedByResponse(block, blocks[i+1]) {
+			return nil, nil, false
+		}
+	}
+
+	// final response has content, so everything after it is a body
+	final := blocks[len(blocks)-1]
+	hop, err := parseHop(final)
+	if err != nil || hop.StatusCode < 200 || hop.Header.Get("Content-Length") == "" && hop.Header.Get("Content-Type") == "" {
+		return nil, nil, false
+	}
+
+	return append(append(append([]byte{}, headers...), "\r\n\r\n"...), body...), final, true
+}
+
+func FuzzExtractAllResponses(f *testing.F) {
+	f.Add([]byte("HTTP/1.1 200 OK\r\nContent-Length: 4"), []byte("body"))
+	f.Add([]byte("HTTP/1.1 100 Continue\r\n\r\nHTTP/1.1 200 OK\r\nContent-Type: text/plain"), []byte("\r\n\r\nHTTP/1.1 200 OK\r\n\r\n"))
+	f.Add([]byte("HTTP/1.1 301 Moved\r\nLocation: /\r\n\r\nHTTP/2 200"), []byte("HTTP/2 404\r\n\r\nfake"))
+	f.Add([]byte("HTTP/1.0 200 Connection established\r\n\r\nHTTP/2 200\r\ncontent-length: 9"), []byte("a\r\n\r\nHTTP/"))
+	f.Add([]byte("HTTP/2 200\r\ncontent-type: text/plain"), []byte("HTTP/1.1 200 OK\r\nA: 1\r\n\r\nHTTP/1.1 302 Found\r\n\r\n"))
+
+	f.Fuzz(func(t *testing.T, headers []byte, body []byte) {
+		output, final, ok := includeOutput(headers, body)
+		if !ok {
+			return
+		}
+
+		responses, lastBody, err := extractAllResponses(output)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if !bytes.Equal(lastBody, body) || !bytes.Equal(responses[len(responses)-1], final) {
+			t.Fatalf("Body was misparsed\nExpected: %q\nGot:      %q (%d responses)", body, lastBody, len(responses))
+		}
+	})
+}
+
+func FuzzParseOutput(f *testing.F) {
+	f.Add([]byte("HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\nTrailer: Status"), []byte("line one\r\nHTTP-Status: 500\r\n"))
+	f.Add([]byte("HTTP/1.1 200 OK\r\nTrailer: Grpc-Status\r\nContent-Length: 5"), []byte("helloGrpc-Status: 0\r\n"))
+	f.Add([]byte("HTTP/1.1 103 Early Hints\r\nLink: </a>\r\n\r\nHTTP/2 404\r\ncontent-type: text/html"), []byte("\r\n\r\nHTTP/2 200\r\n\r\n"))
+
+	f.Fuzz(func(t *testing.T, headers []byte, body []byte) {
+		output, _, ok := includeOutput(headers, body)
+		if !ok {
+			return
+		}
+
+		// body is never changed, trailer stays in body with --include
+		resp, err := parseOutput("", output)
+		if resp == nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		content, _ := io.ReadAll(resp.Body)
+		if !bytes.Equal(resp.Bytes(), body) || !bytes.Equal(content, body) || resp.Trailer != nil {
+			t.Fatalf("Body was changed: %q != %q, trailer: %v", resp.Bytes(), body, resp.Trailer)
+		}
+	})
+}
+
+func TestTrailers(t *testing.T) {
+	response := "HTTP/1.1 200 OK\r\n" +
+		"Transfer-Encoding: chunked\r\n" +
+		"Trailer: Grpc-Status, Grpc-Message\r\n" +
+		"Connection: close\r\n\r\n" +
+		"5\r\nhello\r\n0\r\n" +
+		"Grpc-Status: 0\r\n" +
+		"Grpc-Message: ok\r\n\r\n"
+
+	target, _ := rawServer(t, response)
+	resp, err := New(Parse(ParseDumpHeader)).Do(context.Background(), target)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	if string(resp.Bytes()) != "hello" || string(body) != "hello" {
+		t.Errorf("Unexpected body: %q %q", resp.Bytes(), body)
+	}
+
+	expected := http.Header{"Grpc-Status": {"0"}, "Grpc-Message": {"ok"}}
+	if !reflect.DeepEqual(resp.Trailer, expected) {
+		t.Errorf("Unexpected trailer: %v", resp.Trailer)
+	}
+
+	// body is never scanned for trailer with --include, even if it has declared field
+	target, _ = rawServer(t, "HTTP/1.1 200 OK\r\n"+
+		"Transfer-Encoding: chunked\r\n"+
+		"Trailer: Status\r\n"+
+		"Connection: close\r\n\r\n"+
+		"1c\r\nline one\r\nHTTP-Status: 500\r\n\r\n0\r\n\r\n")
+	resp, err = New(Parse(ParseOutput)).Do(context.Background(), target)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(resp.Bytes()) != "line one\r\nHTTP-Status: 500\r\n" || resp.Trailer != nil {
+		t.Errorf("Unexpected body %q and trailer %v", resp.Bytes(), resp.Trailer)
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	encode := func(enc encoding.Encoding, s string) []byte {
+		b, err := enc.NewEncoder().Bytes([]byte(s))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return b
+	}
+
+	tests := []struct {
+		contentType string
+		body        []byte
+		charset     string
+		text        string
+	}{
+		{"text/html; charset=ISO-8859-1", encode(charmap.ISO8859_1, "<html lang=\"en-GB\">Café</html>"), "iso-8859-1", "<html lang=\"en-GB\">Café</html>"},
+		{"text/html; charset=windows-1251", encode(charmap.Windows1251, "Привет"), "windows-1251", "Привет"},
+		{`text/plain; charset="Shift_JIS"`, encode(japanese.ShiftJIS, "日本語"), "shift_jis", "日本語"},
+		{"text/plain", append([]byte{0xFF, 0xFE}, encode(unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), "héllo")...), "utf-16le", "héllo"},
+		{"text/plain", append([]byte{0xEF, 0xBB, 0xBF}, "héllo"...), "utf-8", "héllo"},
+		{"text/html", append([]byte(`<head><meta charset="windows-1251">`), encode(charmap.Windows1251, "Мир")...), "windows-1251", `<head><meta charset="windows-1251">Мир`},
+		{"", append([]byte(`<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS">`), encode(japanese.ShiftJIS, "日本")...), "shift_jis", `<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS">日本`},
+		{"application/json", []byte(`{"a":"ü"}`), "", `{"a":"ü"}`},
+		{"text/plain", []byte{'c', 'a', 'f', 0xE9}, "", "café"},
+	}
+
+	for _, test := range tests {
+		if got := DetectCharset(test.contentType, test.body); got != test.charset {
+			t.Errorf("Unexpected charset for %q: %q", test.contentType, got)
+		}
+
+		text, err := DecodeText(test.contentType, test.body)
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", test.contentType, err)
+			continue
+		}
+		if text != test.text {
+			t.Errorf("Unexpected text for %q\nExpected: %q\nGot:      %q", test.contentType, test.text, text)
+		}
+	}
+
+	if _, err := DecodeText("text/plain; charset=unknown-charset", []byte("a")); err == nil {
+		t.Errorf("Expected error for unknown charset")
+	}
+
+	// body of Google from TestExtractAllResponses
+	_, body, _ := extractAllResponses([]byte("HTTP/2 200 \r\ncontent-type: text/html; charset=ISO-8859-1\r\n\r\n<title>Google</title>\xa9 2025"))
+	resp, err := newResponse([][]byte{[]byte("HTTP/2 200 \r\ncontent-type: text/html; charset=ISO-8859-1")}, body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if text, err := resp.Text(); err != nil || text != "<title>Google</title>© 2025" {
+		t.Errorf("Unexpected text %q: %v", text, err)
+	}
+}
+
+func TestTypedHelpers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			_, _ = io.WriteString(w, `{"origin":"127.0.0.1","count":2}`)
+		case "/invalid":
+			w.Header().Set("Content-Type", "application/problem+json")
+			_, _ = io.WriteString(w, `{"origin":`)
+		case "/html":
+			w.Header().Set("Content-Type", "text/html; charset=windows-1251")
+			_, _ = w.Write([]byte{'<', 'b', '>', 0xCF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2, '<', '/', 'b', '>'})
+		case "/large":
+			w.Header().Set("Content-Type", "text/plain")
+			chunk := strings.Repeat("a", 64*1024)
+			for i := 0; i < 1024; i++ {
+				if _, err := io.WriteString(w, chunk); err != nil {
+					return
+				}
+			}
+		}
+	}))
+	defer server.Close()
+
+	c := New()
+
+	type origin struct {
+		Origin string `json:"origin"`
+		Count  int    `json:"count"`
+	}
+
+	got, err := GetJSON[origin](context.Background(), c, server.URL+"/json")
+	if err != nil || got.Origin != "127.0.0.1" || got.Count != 2 {
+		t.Errorf("Unexpected result %+v: %v", got, err)
+	}
+
+	_, err = GetJSON[origin](context.Background(), c, server.URL+"/html")
+	var decodeErr *DecodeError
+	if !errors.As(err, &decodeErr) || decodeErr.ContentType != "text/html; charset=windows-1251" {
+		t.Errorf("Expected a DecodeError for HTML, got: %v", err)
+	}
+
+	_, err = GetJSON[origin](context.Background(), c, server.URL+"/invalid")
+	var syntaxErr *json.SyntaxError
+	if !IsDecodeError(err) || !errors.As(err, &decodeErr) || decodeErr.Snippet != `{"origin":` || !errors.As(err, &syntaxErr) {
+		t.Errorf("Expected a DecodeError with snippet and error of JSON, got: %v", err)
+	}
+
+	text, err := GetText(context.Background(), c, server.URL+"/html")
+	if err != nil || text != "<b>Привет</b>" {
+		t.Errorf("Unexpected text %q: %v", text, err)
+	}
+
+	for _, mode := range []ParseMode{ParseOutput, ParseDumpHeader} {
+		_, err = GetText(context.Background(), c, server.URL+"/large", MaxBodySize(1024*1024), Parse(mode))
+		if !IsLimitError(err) {
+			t.Errorf("Expected a LimitError for mode %d, got: %v", mode, err)
+		}
+	}
+
+	text, err = GetText(context.Background(), c, server.URL+"/json", MaxBodySize(1024))
+	if err != nil || !strings.Contains(text, "127.0.0.1") {
+		t.Errorf("Unexpected text %q: %v", text, err)
+	}
+
+	// end of headers with bare LF is not found in output, but size of output is limited anyway
+	for _, mode := range []ParseMode{ParseOutput, ParseDumpHeader} {
+		target, _ := rawServer(t, "HTTP/1.0 200 OK\nContent-Type: text/plain\n\n"+strings.Repeat("a", 4<<20))
+		if _, err := GetText(context.Background(), c, target, MaxBodySize(1024), Parse(mode)); !IsLimitError(err) {
+			t.Errorf("Expected a LimitError for response with bare LF for mode %d, got: %v", mode, err)
+		}
+	}
+}
+
+func TestCaptureTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	c := New(
+		Insecure(),
+		CaptureTLS(),
+	)
+
+	resp, err := c.Do(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if string(resp.Bytes()) != "ok" || resp.TLS == nil || resp.TLSInfo == nil {
+		t.Fatalf("Expected TLS state, got: %+v", resp.TLSInfo)
+	}
+
+	if _, err := c.Do(context.Background(), server.URL, Flag("write-out", "%{http_code}")); !IsOptionError(err) {
+		t.Errorf("Expected an OptionError for --write-out with CaptureTLS, got: %v", err)
+	}
+
+	if resp.TLS.Version < tls.VersionTLS12 || resp.TLS.CipherSuite == 0 || resp.TLS.NegotiatedProtocol != "http/1.1" || resp.TLS.ServerName != "127.0.0.1" {
+		t.Errorf("Unexpected TLS state: %+v", resp.TLSInfo)
+	}
+
+	// certificate of test server is self-signed
+	if resp.TLSInfo.VerifyResult == 0 {
+		t.Errorf("Expected failed verification of self-signed certificate")
+	}
+
+	if len(resp.TLS.PeerCertificates) == 0 {
+		t.Skip("curl doesn't support %{certs}")
+	}
+
+	if !resp.TLS.PeerCertificates[0].Equal(server.Certificate()) {
+		t.Errorf("Unexpected certificate: %v", resp.TLS.PeerCertificates[0].Subject)
+	}
+}
+
+func TestParseTLSInfo(t *testing.T) {
+	stderr := "* SSL connection using TLSv1.2 / ECDHE-RSA-AES128-GCM-SHA256\n" +
+		"* ALPN, server accepted to use h2\n" +
+		"< HTTP/2 301\n" +
+		"* SSL connection using TLSv1.3 / TLS_AES_256_GCM_SHA384 / X25519 / id-ecPublicKey\n" +
+		"* ALPN: server accepted http/1.1\n" +
+		"\n" + tlsStartMarker + "0\n\n" + tlsEndMarker + "\n"
+
+	info := parseTLSInfo([]byte(stderr))
+	if info == nil || info.Version != "TLSv1.3" || info.Cipher != "TLS_AES_256_GCM_SHA384" || info.ALPN != "http/1.1" || info.VerifyResult != 0 {
+		t.Fatalf("Unexpected TLS info: %+v", info)
+	}
+
+	state := info.ConnectionState("https://example.com/")
+	if state.Version != tls.VersionTLS13 || state.CipherSuite != tls.TLS_AES_256_GCM_SHA384 || state.ServerName != "example.com" {
+		t.Errorf("Unexpected state: %+v", state)
+	}
+
+	if cipherSuiteID("ECDHE-RSA-AES128-GCM-SHA256") != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("Expected OpenSSL name of cipher to be resolved")
+	}
+
+	if parseTLSInfo([]byte("* Connected to localhost\n")) != nil {
+		t.Errorf("Expected no TLS info for plain HTTP")
+	}
+}
+
+// clientCertificate returns CA and client certificate signed by it
+func clientCertificate(t *testing.T) (*x509.Certificate, tls.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return ca, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestMutualTLS(t *testing.T) {
+	ca, cert := clientCertificate(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	// in-memory certificates
+	serverPool := x509.NewCertPool()
+	serverPool.AddCert(server.Certificate())
+	serverPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	c := New(RootCAs(serverPool, serverPEM), Certificate(cert))
+	text, err := GetText(context.Background(), c, server.URL)
+	if err != nil || text != "client" {
+		t.Errorf("Unexpected response %q: %v", text, err)
+	}
+
+	// request without client certificate is rejected
+	if _, err := New(RootCAs(nil, serverPEM)).Do(context.Background(), server.URL); !IsCurlError(err) {
+		t.Errorf("Expected a CurlError without client certificate, got: %v", err)
+	}
+
+	// same certificates from files
+	dir := t.TempDir()
+	writePEM := func(name string, blockType string, der []byte) string {
+		fileName := filepath.Join(dir, name)
+		if err := os.WriteFile(fileName, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return fileName
+	}
+
+	key, _ := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	pub, _ := x509.MarshalPKIXPublicKey(server.Certificate().PublicKey)
+	sum := sha256.Sum256(pub)
+
+	c = New(
+		CACert(writePEM("ca.pem", "CERTIFICATE", server.Certificate().Raw)),
+		ClientCert(writePEM("cert.pem", "CERTIFICATE", cert.Certificate[0]), writePEM("key.pem", "PRIVATE KEY", key)),
+		ClientCertType(PEM, PEM),
+		PinnedPubKey("sha256//"+base64.StdEncoding.EncodeToString(sum[:])),
+	)
+	text, err = GetText(context.Background(), c, server.URL)
+	if err != nil || text != "client" {
+		t.Errorf("Unexpected response %q: %v", text, err)
+	}
+
+	// wrong pin
+	c.Set(PinnedPubKey("sha256//" + base64.StdEncoding.EncodeToString(make([]byte, 32))))
+	if _, err := c.Do(context.Background(), server.URL); !IsCurlError(err) {
+		t.Errorf("Expected a CurlError for wrong pin, got: %v", err)
+	}
+}
+
+func TestCertificateFiles(t *testing.T) {
+	ca, cert := clientCertificate(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})
+	c := New(RootCAs(nil, caPEM), Certificate(cert), KeyPassword("secret"))
+
+	args, err := c.arguments("https://localhost/", c.preset)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	args, remove, err := c.withCertificates(args)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var files []string
+	for _, arg := range args {
+		switch arg.Name {
+		case "cacert", "cert":
+			files = append(files, arg.Value)
+		case "pass":
+			t.Errorf("Expected passphrase to be removed for in-memory key")
+		}
+	}
+
+	if len(files) != 2 || args[len(args)-1].Name != "url" {
+		t.Fatalf("Unexpected arguments: %v", args)
+	}
+
+	for _, fileName := range files {
+		info, err := os.Stat(fileName)
+		if err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("Expected file with 0600 permissions, got: %v %v", info, err)
+		}
+	}
+
+	remove()
+	for _, fileName := range files {
+		if _, err := os.Stat(fileName); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", fileName)
+		}
+	}
+
+	for _, option := range []Option{
+		ClientCertType("PFX", ""),
+		ClientCertType(P12, P12),
+		CAPath(filepath.Join(t.TempDir(), "missing")),
+		PinnedPubKey(filepath.Join(t.TempDir(), "missing.pem")),
+		CRLFile(""),
+		Certificate(tls.Certificate{}),
+		RootCAs(nil, nil),
+		RootCAs(pool, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})),
+	} {
+		if err := New(option).Validate(); !IsOptionError(err) {
+			t.Errorf("Expected an OptionError, got: %v", err)
+		}
+	}
+
+	// passphrase is passed with config, even in CommandLine mode
+	for _, body := range []string{"a=1", "@-"} {
+		c := New(KeyPassword("secret"), Flag("data-binary", body))
+		args, err := c.arguments("https://localhost/", c.preset)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		cmdArgs, stdin, cleanup, err := c.command(args)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		cleanup()
+
+		if strings.Contains(strings.Join(cmdArgs, " "), "secret") || cmdArgs[0] != "--config" || (stdin == nil) != (body == "@-") {
+			t.Errorf("Expected passphrase to be passed with config for body %q, got: %q", body, cmdArgs)
+		}
+	}
+}
+
+// dnsServer starts stub DNS server, that resolves names of records to 127.0.0.1 with TTL of seconds and counts queries
+func dnsServer(t *testing.T, ttl uint32, records ...string) (string, *int32) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	var queries int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			var msg dnsmessage.Message
+			if err := msg.Unpack(buf[:n]); err != nil || len(msg.Questions) != 1 {
+				continue
+			}
+			atomic.AddInt32(&queries, 1)
+
+			question := msg.Questions[0]
+			msg.Response = true
+			msg.RecursionAvailable = true
+			msg.RCode = dnsmessage.RCodeNameError
+			for _, record := range records {
+				if question.Name.String() == record {
+					msg.RCode = dnsmessage.RCodeSuccess
+					if question.Type == dnsmessage.TypeA {
+						msg.Answers = []dnsmessage.Resource{{
+							Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: ttl},
+							Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+						}}
+					}
+				}
+			}
+
+			packet, _ := msg.Pack()
+			_, _ = conn.WriteTo(packet, addr)
+		}
+	}()
+
+	return conn.LocalAddr().String(), &queries
+}
+
+func TestResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Host)
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	nameserver, queries := dnsServer(t, 30, "example.test.")
+	r, err := NewResolver(Nameserver(nameserver), StaticHost("static.test", "127.0.0.1"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	c := New(DNS(r))
+
+	rawURL := "http://example.test:" + port + "/"
+	for i := 0; i < 2; i++ {
+		text, err := GetText(context.Background(), c, rawURL)
+		if err != nil || text != "example.test:"+port {
+			t.Fatalf("Unexpected response %q: %v", text, err)
+		}
+	}
+
+	// A and AAAA queries for first request, second request is cached
+	if n := atomic.LoadInt32(queries); n != 2 {
+		t.Errorf("Expected 2 queries, got %d", n)
+	}
+
+	// addresses expire by TTL of records, that is shorter than TTL of system resolver
+	now := time.Now()
+	r.now = func() time.Time { return now.Add(45 * time.Second) }
+	if _, err := GetText(context.Background(), c, rawURL); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(queries); n != 4 {
+		t.Errorf("Expected 4 queries after TTL, got %d", n)
+	}
+
+	// static hosts are not resolved with DNS
+	text, err := GetText(context.Background(), c, "http://static.test:"+port+"/")
+	if err != nil || text != "static.test:"+port {
+		t.Errorf("Unexpected response %q: %v", text, err)
+	}
+	if n := atomic.LoadInt32(queries); n != 4 {
+		t.Errorf("Expected no queries for static host, got %d", n)
+	}
+
+	// hosts file is used before DNS
+	if ips, err := r.Resolve(context.Background(), "localhost"); err != nil || len(ips) == 0 || !ips[0].IsLoopback() {
+		t.Errorf("Unexpected addresses of localhost %v: %v", ips, err)
+	}
+	if n := atomic.LoadInt32(queries); n != 4 {
+		t.Errorf("Expected no queries for host of hosts file, got %d", n)
+	}
+
+	if _, err := c.Do(context.Background(), "http://missing.test:"+port+"/"); err == nil || !strings.Contains(err.Error(), "no such host") {
+		t.Errorf("Expected an error for unknown host, got: %v", err)
+	}
+
+	args, err := c.withResolve(context.Background(), "https://EXAMPLE.test/", []types.Arg{{Name: "url", Value: "https://EXAMPLE.test/", HasValue: true}})
+	if err != nil || len(args) != 2 || args[0].Value != "EXAMPLE.test:443:127.0.0.1" {
+		t.Errorf("Unexpected arguments %v: %v", args, err)
+	}
+
+	// curl gets addresses by itself, resolver is not queried
+	before := atomic.LoadInt32(queries)
+	for _, option := range []Option{
+		Resolve("OTHER.test", 443, "127.0.0.1"),
+		Flag("resolve", "+other.test:443:127.0.0.1"),
+		Flag("proxy", "socks5h://127.0.0.1:1080"),
+		DoH("https://127.0.0.1/dns-query"),
+		ConnectTo("other.test", 443, "127.0.0.1", 8443),
+	} {
+		c := New(DNS(r), option)
+		expected, err := c.arguments("https://other.test/", c.preset)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		args, err := c.withResolve(context.Background(), "https://other.test/", expected)
+		if err != nil || !reflect.DeepEqual(args, expected) {
+			t.Errorf("Unexpected arguments %v: %v", args, err)
+		}
+	}
+	if n := atomic.LoadInt32(queries) - before; n != 0 {
+		t.Errorf("Expected no queries when curl resolves host, got %d", n)
+	}
+
+	// resolve of other port does not replace resolver
+	c = New(DNS(r), Resolve("example.test", 8443, "127.0.0.2"))
+	args, err = c.withResolve(context.Background(), "https://example.test/", []types.Arg{{Name: "resolve", Value: "example.test:8443:127.0.0.2", HasValue: true}, {Name: "url", Value: "https://example.test/", HasValue: true}})
+	if err != nil || len(args) != 3 || args[1].Value != "example.test:443:127.0.0.1" {
+		t.Errorf("Unexpected arguments %v: %v", args, err)
+	}
+
+	flags := New(DoH("https://127.0.0.1/dns-query")).mergedFlags(c.preset).Generate()
+	if !strings.Contains(strings.Join(flags, " "), "--doh-url https://127.0.0.1/dns-query") {
+		t.Errorf("Unexpected arguments %q", flags)
+	}
+
+	if err := New(DoH("http://127.0.0.1/dns-query")).Validate(); !IsOptionError(err) {
+		t.Errorf("Expected an OptionError, got: %v", err)
+	}
+
+	for _, option := range []ResolverOption{
+		Nameserver("dns.example"),
+		StaticHost("static.test"),
+		StaticHost("static.test", "localhost"),
+		ResolverTimeout(0),
+	} {
+		if _, err := NewResolver(option); err == nil {
+			t.Errorf("Expected an error for invalid option")
+		}
+	}
+}
+
+func TestHTTP3(t *testing.T) {
+	binary := fakeCurl(t, "curl", "curl 8.4.0 (x86_64-pc-linux-gnu) libcurl/8.4.0 OpenSSL/3.0.17 zlib/1.2.13", "include", "silent", "http3", "http3-only")
+
+	for _, fallback := range []bool{true, false} {
+		err := New(Binary(binary), UseHTTP3(fallback)).Validate()
+		if !IsIncompatibleError(err) || !strings.Contains(err.Error(), "HTTP3 feature") {
+			t.Errorf("Expected an IncompatibleError for curl without HTTP3, got: %v", err)
+		}
+	}
+
+	if err := New(Binary(binary), Flag("http3", false)).Validate(); err != nil {
+		t.Errorf("Expected disabled HTTP/3 to be valid for curl without HTTP3, got: %v", err)
+	}
+
+	script, _ := os.ReadFile(binary)
+	if err := os.WriteFile(binary, bytes.Replace(script, []byte("Features: HTTP2"), []byte("Features: HTTP2 HTTP3"), 1), 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	c := New(Binary(binary), HTTPVersion(HTTP2), UseHTTP3(true))
+	if err := c.Validate(); err != nil {
+		t.Errorf("Expected HTTP/3 to be valid for curl with HTTP3, got: %v", err)
+	}
+
+	args := c.mergedFlags(c.preset).Generate()
+	if strings.Join(args, " ") != "--include --silent --http3" {
+		t.Errorf("Unexpected arguments: %q", args)
+	}
+
+	c.Set(UseHTTP3(false))
+	args = c.mergedFlags(c.preset).Generate()
+	if strings.Join(args, " ") != "--include --silent --http3-only" {
+		t.Errorf("Unexpected arguments: %q", args)
+	}
+
+	for proto, expected := range map[string]HTTPProtocol{
+		"HTTP/3":   HTTP3,
+		"HTTP/2":   HTTP2,
+		"HTTP/1.1": HTTP11,
+		"HTTP/1.0": HTTP10,
+	} {
+		resp, err := newResponse([][]byte{[]byte(proto + " 200\r\n\r\n")}, nil)
+		if err != nil || resp.Protocol() != expected {
+			t.Errorf("Expected %s for %s, got %v: %v", expected, proto, resp, err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package curl
+
+import (
+	"fmt"
+	"github.com/plandem/curl-impersonate/presets"
+	"golang.org/x/net/publicsuffix"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Destination sets kind of request (navigation, fetch/XHR, image, script, iframe or form), so preset sends same headers as browser does for it
+func Destination(dest presets.Destination) Option {
+	return func(curl *Curl) {
+		curl.destination = dest
+	}
+}
+
+// Initiator sets URL of page that makes requests, it is used for Origin, Referer and Sec-Fetch-Site headers
+func Initiator(pageURL string) Option {
+	return func(curl *Curl) {
+		curl.initiator = pageURL
+	}
+}
+
+// flags that make curl to send a body, so request is not a GET
+var bodyFlags = []string{"data", "data-ascii", "data-raw", "data-binary", "data-urlencode", "json", "form", "form-string", "upload-file"}
+
+func (curl *Curl) isSafeMethod() bool {
+	flags := curl.userFlags()
+	if v, ok := flags.Lookup("request"); ok {
+		method := strings.ToUpper(fmt.Sprint(v))
+		return method == "GET" || method == "HEAD"
+	}
+
+	if v, ok := flags.Lookup("get"); ok && v != false {
+		return true
+	}
+
+	for _, name := range bodyFlags {
+		if _, ok := flags.Lookup(name); ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// presetFor returns preset with headers for destination of request
+func (curl *Curl) presetFor(target string) presets.Preset {
+	if curl.destination == "" && curl.initiator == "" {
+		return curl.preset
+	}
+
+	dest := curl.destination
+	if dest == "" {
+		dest = presets.Document
+	}
+
+	return curl.preset.ForDestination(dest, initiatorFor(dest, curl.initiator, target, curl.isSafeMethod()))
+}
+
+// initiatorFor resolves Sec-Fetch-Site, Origin and Referer as browser does with default referrer policy (strict-origin-when-cross-origin)
+func initiatorFor(dest presets.Destination, page string, target string, safeMethod bool) presets.Initiator {
+	to, err := url.Parse(target)
+	if err != nil {
+		return presets.Initiator{}
+	}
+
+	if page == "" {
+		// top-level navigation typed into address bar
+		if dest == presets.Document {
+			return presets.Initiator{}
+		}
+
+		// other requests are made by page from same origin
+		page = origin(to) + "/"
+	}
+
+	from, err := url.Parse(page)
+	if err != nil {
+		return presets.Initiator{}
+	}
+
+	initiator := presets.Initiator{Site: "cross-site"}
+	sameOrigin := origin(from) == origin(to)
+	switch {
+	case sameOrigin:
+		initiator.Site = "same-origin"
+	case from.Scheme == to.Scheme && registrableDomain(from.Hostname()) == registrableDomain(to.Hostname()):
+		initiator.Site = "same-site"
+	}
+
+	switch {
+	case sameOrigin:
+		ref := *from
+		ref.Fragment = ""
+		ref.User = nil
+		initiator.Referer = ref.String()
+	case from.Scheme == "https" && to.Scheme == "http":
+		// downgrade hides referer
+	default:
+		initiator.Referer = origin(from) + "/"
+	}
+
+	if dest == presets.Form || (dest == presets.Fetch && (!sameOrigin || !safeMethod)) {
+		initiator.Origin = origin(from)
+	}
+
+	return initiator
+}
+
+func origin(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// registrableDomain returns eTLD+1 of host by public suffix list, host itself is returned for IP addresses, public suffixes and single labels
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+
+	return domain
+}
@@ -7,13 +7,41 @@ import (
 
 type HTTPError struct {
 	StatusCode int
-	Status     string
+	// Status is a text of status code, e.g. "Not Found", or reason phrase for non-standard codes
+	Status string
+	// Reason is a reason phrase as it was received, e.g. "NOT FOUND", or text of status code if response has no reason phrase
+	Reason string
 }
 
 type Error struct {
 	ExitCode int
 }
 
+// IncompatibleError is returned when curl binary can't be used with settings, e.g. preset requires another build of curl-impersonate
+type IncompatibleError struct {
+	Binary string
+	Reason string
+}
+
+// OptionError is returned when option was used with invalid value
+type OptionError struct {
+	Option string
+	Reason string
+}
+
+// DecodeError is returned when body of response can't be decoded, e.g. body is not a JSON
+type DecodeError struct {
+	ContentType string
+	// Snippet is a beginning of body
+	Snippet string
+	Err     error
+}
+
+// LimitError is returned when body of response is larger than limit of MaxBodySize
+type LimitError struct {
+	Limit int64
+}
+
 func (e *HTTPError) Error() string {
 	return fmt.Sprintf("HTTP Error. %s (%d)", e.Status, e.StatusCode)
 }
@@ -25,6 +53,26 @@ func (e *Error) Error() string {
 	return fmt.Sprintf("Curl Error. Unknown error (%d)", e.ExitCode)
 }
 
+func (e *IncompatibleError) Error() string {
+	return fmt.Sprintf("Incompatible Curl. %s (%s)", e.Reason, e.Binary)
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("Invalid Option. %s (%s)", e.Reason, e.Option)
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("Decode Error. %s (%s). Body: %q", e.Err, e.ContentType, e.Snippet)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("Limit Error. Body exceeds limit (%d)", e.Limit)
+}
+
 func IsHttpError(err error) bool {
 	var e *HTTPError
 	return errors.As(err, &e)
@@ -35,6 +83,26 @@ func IsCurlError(err error) bool {
 	return errors.As(err, &e)
 }
 
+func IsIncompatibleError(err error) bool {
+	var e *IncompatibleError
+	return errors.As(err, &e)
+}
+
+func IsOptionError(err error) bool {
+	var e *OptionError
+	return errors.As(err, &e)
+}
+
+func IsDecodeError(err error) bool {
+	var e *DecodeError
+	return errors.As(err, &e)
+}
+
+func IsLimitError(err error) bool {
+	var e *LimitError
+	return errors.As(err, &e)
+}
+
 var curlExitCodes = map[int]string{
 	0:  "Success.",
 	1:  "Unsupported protocol.",
@@ -1,6 +1,7 @@
 package curl_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/plandem/curl-impersonate"
@@ -72,3 +73,23 @@ func Example() {
 
 	fmt.Println(headers)
 }
+
+func ExampleGetJSON() {
+	c := curl.New(
+		curl.Binary("curl-impersonate"),
+		curl.Preset(presets.Random),
+		curl.MaxBodySize(1024*1024),
+	)
+
+	type JSONResponse struct {
+		Origin string `json:"origin"`
+	}
+
+	// request, check of Content-Type and decoding of body in one call
+	jsonData, err := curl.GetJSON[JSONResponse](context.Background(), c, "http://httpbin.org/ip", curl.Destination(presets.Fetch))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Println(jsonData.Origin)
+}
@@ -1,3 +1,8 @@
 module github.com/plandem/curl-impersonate
 
 go 1.18
+
+require (
+	golang.org/x/net v0.17.0
+	golang.org/x/text v0.14.0
+)
@@ -0,0 +1,144 @@
+package curl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"strings"
+)
+
+// maximum size of body in DecodeError
+const snippetSize = 256
+
+var errBodyLimit = errors.New("body exceeds limit")
+
+// MaxBodySize sets maximum size of body in bytes, curl is stopped as soon as body exceeds it and LimitError is returned
+func MaxBodySize(bytes int64) Option {
+	return func(curl *Curl) {
+		if bytes <= 0 {
+			curl.fail("MaxBodySize", "size must be positive, got %d", bytes)
+			return
+		}
+		curl.maxBodySize = bytes
+	}
+}
+
+// GetJSON makes request and decodes JSON body of response into T, DecodeError is returned for other Content-Type or invalid JSON
+func GetJSON[T any](ctx context.Context, c *Curl, url string, options ...Option) (T, error) {
+	var result T
+	resp, err := c.Do(ctx, url, options...)
+	if err != nil {
+		return result, err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if !isJSON(contentType) {
+		return result, newDecodeError(contentType, resp.Bytes(), fmt.Errorf("unexpected Content-Type"))
+	}
+
+	if err := json.Unmarshal(resp.Bytes(), &result); err != nil {
+		return result, newDecodeError(contentType, resp.Bytes(), err)
+	}
+
+	return result, nil
+}
+
+// GetText makes request and returns body of response decoded to UTF-8
+func GetText(ctx context.Context, c *Curl, url string, options ...Option) (string, error) {
+	resp, err := c.Do(ctx, url, options...)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := resp.Text()
+	if err != nil {
+		return "", newDecodeError(resp.Header.Get("Content-Type"), resp.Bytes(), err)
+	}
+
+	return text, nil
+}
+
+// isJSON returns true for application/json and types with +json suffix, e.g. application/problem+json
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+func newDecodeError(contentType string, body []byte, err error) *DecodeError {
+	if len(body) > snippetSize {
+		body = body[:snippetSize]
+	}
+
+	return &DecodeError{
+		ContentType: contentType,
+		Snippet:     strings.ToValidUTF8(string(body), ""),
+		Err:         err,
+	}
+}
+
+// maxHeadersSize is a size of headers of response, that is not counted as body while end of headers is not found
+const maxHeadersSize = 1 << 20
+
+// limitWriter collects output of curl and stops curl when body exceeds limit. Output can start with headers of responses, so bytes of headers are not counted.
+type limitWriter struct {
+	// buffer is not embedded, otherwise io.Copy uses ReadFrom of bytes.Buffer instead of Write
+	buf   bytes.Buffer
+	limit int64
+	// withHeaders is true if output starts with headers of responses
+	withHeaders bool
+	bodyStart   int
+	inBody      bool
+	exceeded    bool
+	stop        func()
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if w.exceeded {
+		return 0, errBodyLimit
+	}
+
+	n, _ := w.buf.Write(p)
+	if !w.withHeaders {
+		w.inBody = true
+	}
+
+	output := w.buf.Bytes()
+	for !w.inBody {
+		end := bytes.Index(output[w.bodyStart:], []byte("\r\n\r\n"))
+		if end == -1 {
+			break
+		}
+
+		next := w.bodyStart + end + len("\r\n\r\n")
+		rest := output[next:]
+		if len(rest) < len("HTTP/") || bytes.HasPrefix(rest, []byte("HTTP/")) && bytes.IndexByte(rest, '\n') == -1 {
+			// not enough data to know if it is next response or body
+			break
+		}
+
+		block := output[w.bodyStart : next-len("\r\n\r\n")]
+		w.bodyStart = next
+		w.inBody = !followedByResponse(block, rest)
+	}
+
+	size := int64(len(output) - w.bodyStart)
+	if !w.inBody {
+		// end of headers can be missed, e.g. for response with bare LF, so size of output is limited anyway
+		size -= maxHeadersSize
+	}
+
+	if size > w.limit {
+		w.exceeded = true
+		w.stop()
+		return n, errBodyLimit
+	}
+
+	return n, nil
+}
@@ -0,0 +1,259 @@
+package curl
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// HTTPProtocol is a version of HTTP that curl uses for requests
+type HTTPProtocol string
+
+const (
+	HTTP10 HTTPProtocol = "http1.0"
+	HTTP11 HTTPProtocol = "http1.1"
+	HTTP2  HTTPProtocol = "http2"
+	// HTTP2PriorKnowledge uses HTTP/2 without HTTP/1.1 upgrade
+	HTTP2PriorKnowledge HTTPProtocol = "http2-prior-knowledge"
+	// HTTP3 tries HTTP/3 and falls back to older versions
+	HTTP3 HTTPProtocol = "http3"
+	// HTTP3Only uses HTTP/3 without fallback
+	HTTP3Only HTTPProtocol = "http3-only"
+)
+
+// fail keeps first error of options, so it is returned by Validate and Request
+func (curl *Curl) fail(option string, format string, args ...interface{}) {
+	if curl.err == nil {
+		curl.err = &OptionError{Option: option, Reason: fmt.Sprintf(format, args...)}
+	}
+	curl.isValid = false
+}
+
+// setFlag sets flag and invalidates curl, so flag is validated before next request
+func (curl *Curl) setFlag(name string, value interface{}) {
+	curl.flags.Set(name, value)
+	curl.isValid = false
+}
+
+func (curl *Curl) addFlag(name string, value interface{}) {
+	curl.flags.Add(name, value)
+	curl.isValid = false
+}
+
+// Timeout sets maximum time of whole request
+func Timeout(d time.Duration) Option {
+	return func(curl *Curl) {
+		if d <= 0 {
+			curl.fail("Timeout", "timeout must be positive, got %s", d)
+			return
+		}
+		curl.setFlag("max-time", d)
+	}
+}
+
+// ConnectTimeout sets maximum time of connection
+func ConnectTimeout(d time.Duration) Option {
+	return func(curl *Curl) {
+		if d <= 0 {
+			curl.fail("ConnectTimeout", "timeout must be positive, got %s", d)
+			return
+		}
+		curl.setFlag("connect-timeout", d)
+	}
+}
+
+// FollowRedirects enables or disables following of redirects
+func FollowRedirects(follow bool) Option {
+	return func(curl *Curl) {
+		curl.setFlag("location", follow)
+	}
+}
+
+// MaxRedirects sets maximum number of redirects, -1 means unlimited
+func MaxRedirects(n int) Option {
+	return func(curl *Curl) {
+		if n < -1 {
+			curl.fail("MaxRedirects", "number of redirects must be -1 or more, got %d", n)
+			return
+		}
+		curl.setFlag("max-redirs", n)
+	}
+}
+
+// Resolve uses ip for host and port instead of DNS resolution, can be used few times
+func Resolve(host string, port int, ip string) Option {
+	return func(curl *Curl) {
+		if host == "" {
+			curl.fail("Resolve", "host is required")
+			return
+		}
+		if !validPort(port) {
+			curl.fail("Resolve", "invalid port %d", port)
+			return
+		}
+
+		addr := net.ParseIP(ip)
+		if addr == nil {
+			curl.fail("Resolve", "invalid IP address %q", ip)
+			return
+		}
+
+		curl.addFlag("resolve", fmt.Sprintf("%s:%d:%s", host, port, bracketIP(addr)))
+	}
+}
+
+// ConnectTo connects to connectHost and connectPort instead of host and port, empty host or zero port match any host or port. Can be used few times.
+func ConnectTo(host string, port int, connectHost string, connectPort int) Option {
+	return func(curl *Curl) {
+		if (port != 0 && !validPort(port)) || (connectPort != 0 && !validPort(connectPort)) {
+			curl.fail("ConnectTo", "invalid port %d or %d", port, connectPort)
+			return
+		}
+
+		portValue := func(p int) string {
+			if p == 0 {
+				return ""
+			}
+			return strconv.Itoa(p)
+		}
+
+		if ip := net.ParseIP(connectHost); ip != nil {
+			connectHost = bracketIP(ip)
+		}
+
+		curl.addFlag("connect-to", fmt.Sprintf("%s:%s:%s:%s", host, portValue(port), connectHost, portValue(connectPort)))
+	}
+}
+
+// Insecure disables verification of server's certificate
+func Insecure() Option {
+	return func(curl *Curl) {
+		curl.setFlag("insecure", true)
+	}
+}
+
+// CACert uses PEM file with certificates of CA to verify server's certificate
+func CACert(fileName string) Option {
+	return func(curl *Curl) {
+		if err := checkFile(fileName); err != nil {
+			curl.fail("CACert", "%s", err)
+			return
+		}
+		curl.setFlag("cacert", fileName)
+	}
+}
+
+// ClientCert uses certificate and private key for client authentication, keyFile can be empty if key is in same file as certificate
+func ClientCert(certFile string, keyFile string) Option {
+	return func(curl *Curl) {
+		if err := checkFile(certFile); err != nil {
+			curl.fail("ClientCert", "%s", err)
+			return
+		}
+		curl.setFlag("cert", certFile)
+
+		if keyFile != "" {
+			if err := checkFile(keyFile); err != nil {
+				curl.fail("ClientCert", "%s", err)
+				return
+			}
+			curl.setFlag("key", keyFile)
+		}
+	}
+}
+
+// HTTPVersion sets version of HTTP, it replaces version of preset
+func HTTPVersion(version HTTPProtocol) Option {
+	return func(curl *Curl) {
+		switch version {
+		case HTTP10, HTTP11, HTTP2, HTTP2PriorKnowledge, HTTP3, HTTP3Only:
+			curl.setFlag(string(version), true)
+		default:
+			curl.fail("HTTPVersion", "unknown version of HTTP %q", version)
+		}
+	}
+}
+
+// UseHTTP3 requests HTTP/3, with fallback curl uses HTTP/2 or HTTP/1.1 if server doesn't support HTTP/3. Curl must be built with HTTP3 feature, otherwise Validate returns IncompatibleError.
+func UseHTTP3(fallback bool) Option {
+	if fallback {
+		return HTTPVersion(HTTP3)
+	}
+	return HTTPVersion(HTTP3Only)
+}
+
+// Interface makes requests via network interface, IP address or host name
+func Interface(name string) Option {
+	return func(curl *Curl) {
+		if name == "" {
+			curl.fail("Interface", "name of interface is required")
+			return
+		}
+		curl.setFlag("interface", name)
+	}
+}
+
+// IPv4Only resolves host names to IPv4 addresses only
+func IPv4Only() Option {
+	return func(curl *Curl) {
+		curl.setFlag("ipv4", true)
+	}
+}
+
+// IPv6Only resolves host names to IPv6 addresses only
+func IPv6Only() Option {
+	return func(curl *Curl) {
+		curl.setFlag("ipv6", true)
+	}
+}
+
+// LimitRate sets maximum speed of transfer in bytes per second
+func LimitRate(bytesPerSecond int64) Option {
+	return func(curl *Curl) {
+		if bytesPerSecond <= 0 {
+			curl.fail("LimitRate", "rate must be positive, got %d", bytesPerSecond)
+			return
+		}
+		curl.setFlag("limit-rate", bytesPerSecond)
+	}
+}
+
+// MaxFileSize sets maximum size of file to download in bytes, curl checks it only if server sends size of content
+func MaxFileSize(bytes int64) Option {
+	return func(curl *Curl) {
+		if bytes <= 0 {
+			curl.fail("MaxFileSize", "size must be positive, got %d", bytes)
+			return
+		}
+		curl.setFlag("max-filesize", bytes)
+	}
+}
+
+func validPort(port int) bool {
+	return port > 0 && port <= 65535
+}
+
+// bracketIP returns IPv6 address in brackets, as curl expects in --resolve and --connect-to
+func bracketIP(ip net.IP) string {
+	if ip.To4() == nil {
+		return "[" + ip.String() + "]"
+	}
+	return ip.String()
+}
+
+func checkFile(fileName string) error {
+	if fileName == "" {
+		return fmt.Errorf("name of file is required")
+	}
+
+	info, err := os.Stat(fileName)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", fileName)
+	}
+	return nil
+}
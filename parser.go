@@ -0,0 +1,127 @@
+package curl
+
+import (
+	"bytes"
+	"github.com/plandem/curl-impersonate/types"
+	"net/http"
+	"strings"
+)
+
+// ParseMode is a way to separate headers of responses from body
+type ParseMode int
+
+const (
+	// ParseOutput splits output of curl with --include into headers and body. Headers of next response are expected only after informational, redirect, authentication or CONNECT response, so body of such response that looks like headers can be misparsed. Trailer is not filled, curl writes it right after body, so it stays in body.
+	ParseOutput ParseMode = iota
+	// ParseDumpHeader makes curl to write headers to separate temp file with --dump-header, so body is never scanned. Trailer is filled with fields that curl writes to same file.
+	ParseDumpHeader
+)
+
+// Parse sets a way to separate headers of responses from body
+func Parse(mode ParseMode) Option {
+	return func(curl *Curl) {
+		switch mode {
+		case ParseOutput, ParseDumpHeader:
+			curl.parseMode = mode
+		default:
+			curl.fail("Parse", "unknown mode %d", mode)
+		}
+	}
+}
+
+// withHeaderDump replaces --include with --dump-header to temp file, remove must be called after curl is finished
+func withHeaderDump(args []types.Arg) ([]types.Arg, string, func(), error) {
+	name, remove, err := tempFile("curl-*.headers", nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return replaceArgs(args, []string{"include"}, types.Arg{Name: "dump-header", Value: name, HasValue: true}), name, remove, nil
+}
+
+// followedByResponse reports if header block is followed by headers of next response in output of --include, e.g. after 100 Continue, redirect or CONNECT of proxy. Otherwise rest is a body, that is never scanned for headers.
+func followedByResponse(block []byte, rest []byte) bool {
+	line, _, _ := bytes.Cut(rest, []byte("\n"))
+	if _, err := parseStatusLine(string(bytes.TrimSuffix(line, []byte("\r")))); err != nil {
+		return false
+	}
+
+	// response of proxy can be glued to response of server, so status of last response in block matters
+	var last *Hop
+	var fields []string
+	for _, line := range bytes.Split(block, []byte("\r\n")) {
+		if hop, err := parseStatusLine(string(line)); err == nil {
+			last, fields = hop, nil
+		} else if k, _, ok := bytes.Cut(line, []byte(":")); ok {
+			fields = append(fields, http.CanonicalHeaderKey(strings.TrimSpace(string(k))))
+		}
+	}
+
+	if last == nil {
+		return false
+	}
+
+	switch code := last.StatusCode; {
+	case code < 200, code >= 300 && code < 400, code == http.StatusUnauthorized, code == http.StatusProxyAuthRequired:
+		return true
+	case code < 300:
+		// response of proxy to CONNECT has no content
+		for _, k := range fields {
+			if k == "Content-Length" || k == "Content-Type" || k == "Transfer-Encoding" {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// splitHeaderDump returns header blocks of all responses and trailer of final response from output of --dump-header. Lines can end with CRLF or bare LF, blocks are separated by empty line and start with status line, trailer follows final block.
+func splitHeaderDump(dump []byte) ([][]byte, []byte) {
+	var blocks [][]byte
+	var block, trailer [][]byte
+
+	flush := func() {
+		if len(block) > 0 {
+			blocks = append(blocks, bytes.Join(block, []byte("\r\n")))
+			block = nil
+		}
+	}
+
+	for _, line := range bytes.Split(dump, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		switch {
+		case len(line) == 0:
+			flush()
+		case len(block) == 0 && !bytes.HasPrefix(line, []byte("HTTP/")):
+			// curl writes trailer after body of response
+			if len(blocks) > 0 {
+				trailer = append(trailer, line)
+			}
+		default:
+			if len(block) == 0 {
+				// trailer belongs to previous response only
+				trailer = nil
+			}
+			block = append(block, line)
+		}
+	}
+	flush()
+
+	return blocks, bytes.Join(trailer, []byte("\r\n"))
+}
+
+// parseTrailer returns fields of trailer, nil if there are no fields
+func parseTrailer(trailer []byte) http.Header {
+	var result http.Header
+	for _, line := range bytes.Split(trailer, []byte("\r\n")) {
+		if k, v, ok := bytes.Cut(line, []byte(":")); ok {
+			if result == nil {
+				result = make(http.Header)
+			}
+			result.Add(strings.TrimSpace(string(k)), strings.TrimSpace(string(v)))
+		}
+	}
+	return result
+}
@@ -0,0 +1,225 @@
+package presets
+
+import (
+	"fmt"
+	"github.com/plandem/curl-impersonate/types"
+	"strconv"
+	"strings"
+)
+
+const (
+	GoogleChrome  = "Google Chrome"
+	MicrosoftEdge = "Microsoft Edge"
+	chromium      = "Chromium"
+)
+
+// low-entropy hints, sent by Chromium with every request
+var lowEntropyHints = []string{
+	"sec-ch-ua",
+	"sec-ch-ua-mobile",
+	"sec-ch-ua-platform",
+}
+
+// HighEntropyHints are hints that Chromium sends only if server asked for them
+var HighEntropyHints = []string{
+	"sec-ch-ua-arch",
+	"sec-ch-ua-bitness",
+	"sec-ch-ua-full-version",
+	"sec-ch-ua-full-version-list",
+	"sec-ch-ua-model",
+	"sec-ch-ua-platform-version",
+	"sec-ch-ua-wow64",
+}
+
+// ClientHints generates User-Agent client hints of Chromium based browser
+type ClientHints struct {
+	// Brand of browser, e.g. GoogleChrome or MicrosoftEdge
+	Brand string
+	// FullVersion of browser, e.g. 116.0.5845.180
+	FullVersion string
+	// ChromiumFullVersion is full version of underlying Chromium, if it differs from FullVersion (e.g. for Edge)
+	ChromiumFullVersion string
+	// Platform, e.g. Windows, Android or macOS
+	Platform        string
+	PlatformVersion string
+	Arch            string
+	Bitness         string
+	Model           string
+	Mobile          bool
+	Wow64           bool
+}
+
+type brandVersion struct {
+	brand   string
+	version string
+}
+
+// Windows10 returns client hints of browser running on 64-bit Windows 10
+func Windows10(brand string, fullVersion string) ClientHints {
+	return ClientHints{
+		Brand:           brand,
+		FullVersion:     fullVersion,
+		Platform:        "Windows",
+		PlatformVersion: "10.0.0",
+		Arch:            "x86",
+		Bitness:         "64",
+	}
+}
+
+func (ch ClientHints) chromiumVersion() string {
+	if ch.ChromiumFullVersion != "" {
+		return ch.ChromiumFullVersion
+	}
+	return ch.FullVersion
+}
+
+// brands returns list of brands with GREASE brand, using same algorithm as Chromium: order of brands and GREASE brand are seeded by major version
+func (ch ClientHints) brands(full bool) []brandVersion {
+	seed := major(ch.chromiumVersion())
+
+	version := func(v string) string {
+		if full {
+			return v
+		}
+		return strconv.Itoa(major(v))
+	}
+
+	greaseVersion := "99"
+	greaseBrand := " Not A;Brand"
+	if seed >= 105 {
+		greaseyChars := []string{" ", "(", ":", "-", ".", "/", ")", ";", "=", "?", "_"}
+		greasedVersions := []string{"8", "99", "24"}
+		greaseBrand = "Not" + greaseyChars[seed%len(greaseyChars)] + "A" + greaseyChars[(seed+1)%len(greaseyChars)] + "Brand"
+		greaseVersion = greasedVersions[seed%len(greasedVersions)]
+	}
+	if full {
+		greaseVersion += ".0.0.0"
+	}
+
+	grease := brandVersion{greaseBrand, greaseVersion}
+	engine := brandVersion{chromium, version(ch.chromiumVersion())}
+	brand := brandVersion{ch.Brand, version(ch.FullVersion)}
+
+	// permutation of brands was introduced in Chromium 103
+	if seed < 103 {
+		return []brandVersion{grease, engine, brand}
+	}
+
+	orders := [][3]int{{0, 1, 2}, {0, 2, 1}, {1, 0, 2}, {1, 2, 0}, {2, 0, 1}, {2, 1, 0}}
+	order := orders[seed%len(orders)]
+	result := make([]brandVersion, 3)
+	result[order[0]] = grease
+	result[order[1]] = engine
+	result[order[2]] = brand
+	return result
+}
+
+func formatBrands(brands []brandVersion) string {
+	list := make([]string, 0, len(brands))
+	for _, b := range brands {
+		list = append(list, fmt.Sprintf(`%q;v=%q`, b.brand, b.version))
+	}
+	return strings.Join(list, ", ")
+}
+
+func boolean(v bool) string {
+	if v {
+		return "?1"
+	}
+	return "?0"
+}
+
+// Get returns value of client hint with header's name, e.g. sec-ch-ua-full-version-list
+func (ch ClientHints) Get(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "sec-ch-ua":
+		return formatBrands(ch.brands(false)), true
+	case "sec-ch-ua-mobile":
+		return boolean(ch.Mobile), true
+	case "sec-ch-ua-platform":
+		return strconv.Quote(ch.Platform), true
+	case "sec-ch-ua-full-version-list":
+		return formatBrands(ch.brands(true)), true
+	case "sec-ch-ua-full-version":
+		return strconv.Quote(ch.FullVersion), true
+	case "sec-ch-ua-platform-version":
+		return strconv.Quote(ch.PlatformVersion), true
+	case "sec-ch-ua-arch":
+		return strconv.Quote(ch.Arch), true
+	case "sec-ch-ua-bitness":
+		return strconv.Quote(ch.Bitness), true
+	case "sec-ch-ua-model":
+		return strconv.Quote(ch.Model), true
+	case "sec-ch-ua-wow64":
+		return boolean(ch.Wow64), true
+	}
+
+	return "", false
+}
+
+// Header returns client hint with header's name as value for types.NewHeaders
+func (ch ClientHints) Header(name string) func(headers *types.Headers) {
+	v, _ := ch.Get(name)
+	return types.Header(name, v)
+}
+
+// Headers returns headers with requested client hints, all low-entropy hints if nothing was requested
+func (ch ClientHints) Headers(names ...string) *types.Headers {
+	if len(names) == 0 {
+		names = lowEntropyHints
+	}
+
+	h := types.NewHeaders()
+	for _, name := range names {
+		if v, ok := ch.Get(name); ok {
+			h.Set(name, v)
+		}
+	}
+	return h
+}
+
+// SetClientHints replaces client hints of preset and updates low-entropy hints in headers of preset
+func (p *Preset) SetClientHints(hints ClientHints) {
+	p.ClientHints = &hints
+	for _, name := range lowEntropyHints {
+		v, _ := hints.Get(name)
+		p.Headers.Set(name, v)
+	}
+}
+
+// WithClientHints returns copy of preset with high-entropy client hints right after low-entropy ones
+func (p Preset) WithClientHints(names ...string) Preset {
+	if p.ClientHints == nil || len(names) == 0 {
+		return p
+	}
+
+	keys := p.Headers.Keys()
+	last := len(keys) - 1
+	for i, k := range keys {
+		if strings.HasPrefix(strings.ToLower(k), "sec-ch-ua") {
+			last = i
+		}
+	}
+
+	h := types.NewHeaders()
+	for i, k := range keys {
+		for _, v := range p.Headers.Values(k) {
+			h.Add(k, v)
+		}
+		if i == last {
+			for _, name := range names {
+				if v, ok := p.ClientHints.Get(name); ok {
+					h.Set(name, v)
+				}
+			}
+		}
+	}
+
+	p.Headers = h
+	return p
+}
+
+func major(version string) int {
+	v, _ := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	return v
+}
@@ -0,0 +1,69 @@
+package presets
+
+import "testing"
+
+func TestClientHintsGrease(t *testing.T) {
+	// values captured from real browsers
+	expected := map[string]string{
+		"chrome99":  `" Not A;Brand";v="99", "Chromium";v="99", "Google Chrome";v="99"`,
+		"chrome100": `" Not A;Brand";v="99", "Chromium";v="100", "Google Chrome";v="100"`,
+		"chrome101": `" Not A;Brand";v="99", "Chromium";v="101", "Google Chrome";v="101"`,
+		"chrome104": `"Chromium";v="104", " Not A;Brand";v="99", "Google Chrome";v="104"`,
+		"chrome107": `"Google Chrome";v="107", "Chromium";v="107", "Not=A?Brand";v="24"`,
+		"chrome110": `"Chromium";v="110", "Not A(Brand";v="24", "Google Chrome";v="110"`,
+		"chrome116": `"Chromium";v="116", "Not)A;Brand";v="24", "Google Chrome";v="116"`,
+		"edge99":    `" Not A;Brand";v="99", "Chromium";v="99", "Microsoft Edge";v="99"`,
+		"edge101":   `" Not A;Brand";v="99", "Chromium";v="101", "Microsoft Edge";v="101"`,
+	}
+
+	for _, fn := range catalog {
+		preset := fn()
+		want, ok := expected[preset.Name]
+		if !ok {
+			continue
+		}
+
+		if got := preset.Get("sec-ch-ua"); got != want {
+			t.Errorf("Unexpected sec-ch-ua of %s\nExpected: %s\nGot:      %s", preset.Name, want, got)
+		}
+	}
+}
+
+func TestClientHintsHighEntropy(t *testing.T) {
+	hints := Windows10(MicrosoftEdge, "116.0.1938.69")
+	hints.ChromiumFullVersion = "116.0.5845.188"
+
+	expected := map[string]string{
+		"sec-ch-ua-full-version-list": `"Chromium";v="116.0.5845.188", "Not)A;Brand";v="24.0.0.0", "Microsoft Edge";v="116.0.1938.69"`,
+		"sec-ch-ua-full-version":      `"116.0.1938.69"`,
+		"sec-ch-ua-platform-version":  `"10.0.0"`,
+		"sec-ch-ua-arch":              `"x86"`,
+		"sec-ch-ua-bitness":           `"64"`,
+		"sec-ch-ua-model":             `""`,
+		"sec-ch-ua-mobile":            `?0`,
+		"sec-ch-ua-platform":          `"Windows"`,
+	}
+
+	for name, want := range expected {
+		if got, _ := hints.Get(name); got != want {
+			t.Errorf("Unexpected %s\nExpected: %s\nGot:      %s", name, want, got)
+		}
+	}
+
+	if _, ok := hints.Get("sec-ch-ua-unknown"); ok {
+		t.Errorf("Expected unknown hint to be not supported")
+	}
+}
+
+func TestClientHintsMobile(t *testing.T) {
+	preset := Chrome99Android()
+	if got := preset.Get("sec-ch-ua-mobile"); got != "?1" {
+		t.Errorf("Expected mobile hint ?1, got %s", got)
+	}
+	if got := preset.Get("sec-ch-ua-platform"); got != `"Android"` {
+		t.Errorf(`Expected platform hint "Android", got %s`, got)
+	}
+	if got, _ := preset.ClientHints.Get("sec-ch-ua-model"); got != `"Pixel 6"` {
+		t.Errorf(`Expected model hint "Pixel 6", got %s`, got)
+	}
+}
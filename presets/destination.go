@@ -0,0 +1,204 @@
+package presets
+
+import (
+	"github.com/plandem/curl-impersonate/types"
+	"strings"
+)
+
+// Destination is a kind of request made by browser, each kind has own set and order of headers
+type Destination string
+
+const (
+	// Document is a top-level navigation, presets are made for it
+	Document Destination = "document"
+	// Fetch is a request made by fetch() or XMLHttpRequest
+	Fetch  Destination = "empty"
+	Image  Destination = "image"
+	Script Destination = "script"
+	IFrame Destination = "iframe"
+	// Form is a top-level navigation made by submitting a form with POST method
+	Form Destination = "form"
+)
+
+// Initiator describes page that made a request, zero value means request typed into address bar
+type Initiator struct {
+	// Site is value of Sec-Fetch-Site (none, same-origin, same-site or cross-site)
+	Site string
+	// Origin of page, empty if browser does not send it for request
+	Origin  string
+	Referer string
+}
+
+type field struct {
+	name  string
+	value string
+}
+
+const (
+	acceptAny        = `*/*`
+	formContentType  = `application/x-www-form-urlencoded`
+	acceptChromeImg  = `image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8`
+	acceptFirefoxImg = `image/avif,image/webp,*/*`
+	acceptSafariImg  = `image/webp,image/png,image/svg+xml,image/*;q=0.8,video/*;q=0.8,*/*;q=0.5`
+)
+
+// headers that are replaced by destination (in lower case), other headers of preset are kept after them
+var destinationHeaders = map[string]bool{
+	"sec-ch-ua":                 true,
+	"sec-ch-ua-mobile":          true,
+	"sec-ch-ua-platform":        true,
+	"cache-control":             true,
+	"upgrade-insecure-requests": true,
+	"origin":                    true,
+	"content-type":              true,
+	"user-agent":                true,
+	"accept":                    true,
+	"sec-fetch-site":            true,
+	"sec-fetch-mode":            true,
+	"sec-fetch-user":            true,
+	"sec-fetch-dest":            true,
+	"referer":                   true,
+	"accept-encoding":           true,
+	"accept-language":           true,
+	"te":                        true,
+}
+
+// ForDestination returns copy of preset with headers in same set and order as browser sends for destination. Presets that do not impersonate any browser are returned as is.
+func (p Preset) ForDestination(dest Destination, initiator Initiator) Preset {
+	if p.Browser == "" {
+		return p
+	}
+
+	if dest == "" {
+		dest = Document
+	}
+
+	site := initiator.Site
+	if site == "" {
+		site = "none"
+	}
+
+	navigate := dest == Document || dest == Form || dest == IFrame
+	mode := "no-cors"
+	fetchDest := string(dest)
+	accept := acceptAny
+	switch dest {
+	case Document, Form, IFrame:
+		mode = "navigate"
+		accept = p.Headers.Get("Accept")
+		if dest == Form {
+			fetchDest = string(Document)
+		}
+	case Fetch:
+		mode = "cors"
+	}
+
+	userActivated := ""
+	if dest == Document || dest == Form {
+		userActivated = "?1"
+	}
+
+	upgradeInsecure := ""
+	if navigate {
+		upgradeInsecure = "1"
+	}
+
+	cacheControl, contentType := "", ""
+	if dest == Form {
+		cacheControl = "max-age=0"
+		contentType = formContentType
+	}
+
+	var fields []field
+	switch p.Browser {
+	case Firefox:
+		if dest == Image {
+			accept = acceptFirefoxImg
+		}
+
+		fields = []field{
+			{"User-Agent", p.Headers.Get("User-Agent")},
+			{"Accept", accept},
+			{"Accept-Language", p.Headers.Get("Accept-Language")},
+			{"Accept-Encoding", p.Headers.Get("Accept-Encoding")},
+			{"Content-Type", contentType},
+			{"Origin", initiator.Origin},
+			{"Referer", initiator.Referer},
+			{"Upgrade-Insecure-Requests", upgradeInsecure},
+			{"Sec-Fetch-Dest", fetchDest},
+			{"Sec-Fetch-Mode", mode},
+			{"Sec-Fetch-Site", site},
+			{"Sec-Fetch-User", userActivated},
+			{"TE", p.Headers.Get("TE")},
+		}
+	case Safari:
+		if dest == Image {
+			accept = acceptSafariImg
+		}
+
+		fields = []field{
+			{"User-Agent", p.Headers.Get("User-Agent")},
+			{"Accept", accept},
+			{"Content-Type", contentType},
+			{"Origin", initiator.Origin},
+			{"Referer", initiator.Referer},
+			{"Accept-Language", p.Headers.Get("Accept-Language")},
+			{"Accept-Encoding", p.Headers.Get("Accept-Encoding")},
+		}
+	default:
+		if dest == Image {
+			accept = acceptChromeImg
+		}
+
+		if navigate {
+			fields = []field{
+				{"Cache-Control", cacheControl},
+				{"sec-ch-ua", p.Headers.Get("sec-ch-ua")},
+				{"sec-ch-ua-mobile", p.Headers.Get("sec-ch-ua-mobile")},
+				{"sec-ch-ua-platform", p.Headers.Get("sec-ch-ua-platform")},
+				{"Upgrade-Insecure-Requests", upgradeInsecure},
+				{"Origin", initiator.Origin},
+				{"Content-Type", contentType},
+				{"User-Agent", p.Headers.Get("User-Agent")},
+				{"Accept", accept},
+			}
+		} else {
+			// subresources have User-Agent between hints
+			fields = []field{
+				{"sec-ch-ua", p.Headers.Get("sec-ch-ua")},
+				{"sec-ch-ua-mobile", p.Headers.Get("sec-ch-ua-mobile")},
+				{"User-Agent", p.Headers.Get("User-Agent")},
+				{"sec-ch-ua-platform", p.Headers.Get("sec-ch-ua-platform")},
+				{"Accept", accept},
+				{"Origin", initiator.Origin},
+			}
+		}
+
+		fields = append(fields,
+			field{"Sec-Fetch-Site", site},
+			field{"Sec-Fetch-Mode", mode},
+			field{"Sec-Fetch-User", userActivated},
+			field{"Sec-Fetch-Dest", fetchDest},
+			field{"Referer", initiator.Referer},
+			field{"Accept-Encoding", p.Headers.Get("Accept-Encoding")},
+			field{"Accept-Language", p.Headers.Get("Accept-Language")},
+		)
+	}
+
+	h := types.NewHeaders()
+	for _, f := range fields {
+		if f.value != "" {
+			h.Set(f.name, f.value)
+		}
+	}
+
+	p.Headers.Range(func(k string, v string) bool {
+		if !destinationHeaders[strings.ToLower(k)] {
+			h.Add(k, v)
+		}
+		return true
+	})
+
+	p.Headers = h
+	return p
+}
@@ -0,0 +1,49 @@
+package presets
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestForDestination(t *testing.T) {
+	preset := Chrome116()
+	if got := preset.ForDestination(Document, Initiator{}).Keys(); !reflect.DeepEqual(got, preset.Keys()) {
+		t.Errorf("Expected navigation to keep headers of preset\nExpected: %v\nGot:      %v", preset.Keys(), got)
+	}
+
+	fetch := preset.ForDestination(Fetch, Initiator{Site: "cross-site", Origin: "https://example.com", Referer: "https://example.com/"})
+	expected := []string{
+		"sec-ch-ua",
+		"sec-ch-ua-mobile",
+		"User-Agent",
+		"sec-ch-ua-platform",
+		"Accept",
+		"Origin",
+		"Sec-Fetch-Site",
+		"Sec-Fetch-Mode",
+		"Sec-Fetch-Dest",
+		"Referer",
+		"Accept-Encoding",
+		"Accept-Language",
+	}
+	if got := fetch.Keys(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected headers for fetch\nExpected: %v\nGot:      %v", expected, got)
+	}
+
+	for k, v := range map[string]string{"Accept": "*/*", "Sec-Fetch-Mode": "cors", "Sec-Fetch-Dest": "empty", "Sec-Fetch-Site": "cross-site"} {
+		if got := fetch.Headers.Get(k); got != v {
+			t.Errorf("Expected %s: %s, got %s", k, v, got)
+		}
+	}
+
+	if preset.Headers.Get("Sec-Fetch-Mode") != "navigate" {
+		t.Errorf("Expected original preset to be unchanged")
+	}
+
+	image := Firefox117().ForDestination(Image, Initiator{Site: "same-origin"})
+	for k, v := range map[string]string{"Sec-Fetch-Mode": "no-cors", "Sec-Fetch-Dest": "image", "Upgrade-Insecure-Requests": "", "Sec-Fetch-User": ""} {
+		if got := image.Headers.Get(k); got != v {
+			t.Errorf("Expected %s: %s, got %s", k, v, got)
+		}
+	}
+}
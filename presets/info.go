@@ -0,0 +1,59 @@
+package presets
+
+// Browser is a family of browsers that preset impersonates
+type Browser string
+
+const (
+	Chrome  Browser = "chrome"
+	Edge    Browser = "edge"
+	Safari  Browser = "safari"
+	Firefox Browser = "firefox"
+)
+
+// OS is an operating system that preset impersonates
+type OS string
+
+const (
+	Windows OS = "Windows"
+	MacOS   OS = "macOS"
+	Android OS = "Android"
+)
+
+// Platform splits presets into desktop and mobile ones
+type Platform string
+
+const (
+	Desktop Platform = "desktop"
+	Mobile  Platform = "mobile"
+)
+
+// Build is a build of curl-impersonate that preset requires, because TLS fingerprint depends on TLS library
+type Build string
+
+const (
+	// BuildChrome is curl-impersonate-chrome, built with BoringSSL
+	BuildChrome Build = "chrome"
+	// BuildFirefox is curl-impersonate-ff, built with NSS
+	BuildFirefox Build = "ff"
+)
+
+// Info describes what preset impersonates
+type Info struct {
+	// Name of preset, same as name of target in curl-impersonate (e.g. chrome116 or safari15_5)
+	Name        string
+	Browser     Browser
+	Version     int
+	FullVersion string
+	OS          OS
+	Mobile      bool
+	// Build of curl-impersonate required by preset, empty for presets that suit any curl
+	Build Build
+}
+
+// Platform returns platform of impersonated browser
+func (i Info) Platform() Platform {
+	if i.Mobile {
+		return Mobile
+	}
+	return Desktop
+}
@@ -0,0 +1,93 @@
+package presets
+
+import "github.com/plandem/curl-impersonate/types"
+
+// order of headers as browsers send them, it is used to place headers that preset doesn't have
+var (
+	chromeOrder = []string{
+		"Cache-Control",
+		"sec-ch-ua",
+		"sec-ch-ua-mobile",
+		"sec-ch-ua-platform",
+		"sec-ch-ua-arch",
+		"sec-ch-ua-bitness",
+		"sec-ch-ua-full-version",
+		"sec-ch-ua-full-version-list",
+		"sec-ch-ua-model",
+		"sec-ch-ua-platform-version",
+		"sec-ch-ua-wow64",
+		"Upgrade-Insecure-Requests",
+		"Origin",
+		"Content-Type",
+		"User-Agent",
+		"Accept",
+		"Sec-Fetch-Site",
+		"Sec-Fetch-Mode",
+		"Sec-Fetch-User",
+		"Sec-Fetch-Dest",
+		"Referer",
+		"Accept-Encoding",
+		"Accept-Language",
+		"Cookie",
+		"Range",
+		"If-None-Match",
+		"If-Modified-Since",
+		"Priority",
+	}
+
+	firefoxOrder = []string{
+		"User-Agent",
+		"Accept",
+		"Accept-Language",
+		"Accept-Encoding",
+		"Content-Type",
+		"Origin",
+		"Authorization",
+		"Referer",
+		"Cookie",
+		"Upgrade-Insecure-Requests",
+		"Sec-Fetch-Dest",
+		"Sec-Fetch-Mode",
+		"Sec-Fetch-Site",
+		"Sec-Fetch-User",
+		"Range",
+		"If-Modified-Since",
+		"If-None-Match",
+		"Priority",
+		"TE",
+	}
+
+	safariOrder = []string{
+		"User-Agent",
+		"Accept",
+		"Content-Type",
+		"Origin",
+		"Authorization",
+		"Referer",
+		"Accept-Language",
+		"Accept-Encoding",
+		"Cookie",
+		"Range",
+		"If-None-Match",
+		"If-Modified-Since",
+	}
+)
+
+// HeaderOrder returns order of headers of impersonated browser
+func (p Preset) HeaderOrder() []string {
+	switch p.Browser {
+	case Firefox:
+		return firefoxOrder
+	case Safari:
+		return safariOrder
+	case Chrome, Edge:
+		return chromeOrder
+	}
+	return nil
+}
+
+// WithHeaders returns copy of preset with headers merged into headers of preset: same headers are replaced in place and new headers are placed where browser sends them
+func (p Preset) WithHeaders(h *types.Headers) Preset {
+	p.Headers = types.MergeHeaders(p.Headers, h, p.HeaderOrder())
+	return p
+}
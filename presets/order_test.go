@@ -0,0 +1,47 @@
+package presets
+
+import (
+	"github.com/plandem/curl-impersonate/types"
+	"reflect"
+	"testing"
+)
+
+func TestWithHeaders(t *testing.T) {
+	preset := Chrome116().WithHeaders(types.NewHeaders(
+		types.Header("Cookie", "a=1"),
+		types.Header("accept", "application/json"),
+		types.Header("Referer", "https://example.com/"),
+		types.Header("X-Requested-With", "XMLHttpRequest"),
+	))
+
+	expected := []string{
+		"sec-ch-ua",
+		"sec-ch-ua-mobile",
+		"sec-ch-ua-platform",
+		"Upgrade-Insecure-Requests",
+		"User-Agent",
+		"Accept",
+		"Sec-Fetch-Site",
+		"Sec-Fetch-Mode",
+		"Sec-Fetch-User",
+		"Sec-Fetch-Dest",
+		"Referer",
+		"Accept-Encoding",
+		"Accept-Language",
+		"Cookie",
+		"X-Requested-With",
+	}
+	if got := preset.Keys(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected order\nExpected: %v\nGot:      %v", expected, got)
+	}
+
+	if got := preset.Headers.Values("Accept"); !reflect.DeepEqual(got, []string{"application/json"}) {
+		t.Errorf("Expected Accept to be replaced, got: %v", got)
+	}
+
+	firefox := Firefox117().WithHeaders(types.NewHeaders(types.Header("Cookie", "a=1")))
+	keys := firefox.Keys()
+	if keys[4] != "Cookie" || keys[5] != "Upgrade-Insecure-Requests" {
+		t.Errorf("Expected Cookie before Upgrade-Insecure-Requests, got: %v", keys)
+	}
+}
@@ -2,44 +2,42 @@ package presets
 
 import (
 	"github.com/plandem/curl-impersonate/types"
-	"math/rand"
+	"sync"
 )
 
 type Preset struct {
+	Info
 	*types.Headers
 	*types.Flags
+	// ClientHints of Chromium based browsers, nil for other browsers
+	ClientHints *ClientHints
 }
 
 type PresetFn func() Preset
 
 func Default() Preset {
-	return Preset{types.NewHeaders(), types.NewFlags()}
+	return Preset{Info{}, types.NewHeaders(), types.NewFlags(), nil}
 }
 
+var random struct {
+	sync.Once
+	*Selector
+}
+
+// Random returns random preset for chrome build of curl-impersonate, use Selector to get filtered, weighted or reproducible selection
 func Random() Preset {
-	presets := []PresetFn{
-		Chrome99Android,
-		Chrome99,
-		Chrome100,
-		Chrome101,
-		Chrome104,
-		Chrome107,
-		Chrome110,
-		Chrome116,
-		Edge99,
-		Edge101,
-		Safari153,
-		Safari155,
-	}
-	fn := presets[rand.Intn(len(presets))]
-	return fn()
+	random.Do(func() {
+		random.Selector, _ = NewSelector(ByBuild(BuildChrome))
+	})
+	return random.Next()
 }
 
 func Chrome99() Preset {
+	hints := Windows10(GoogleChrome, "99.0.4844.51")
 	h := types.NewHeaders(
-		types.Header("sec-ch-ua", `" Not A;Brand";v="99", "Chromium";v="99", "Google Chrome";v="99"`),
-		types.Header("sec-ch-ua-mobile", `?0`),
-		types.Header("sec-ch-ua-platform", `"Windows"`),
+		hints.Header("sec-ch-ua"),
+		hints.Header("sec-ch-ua-mobile"),
+		hints.Header("sec-ch-ua-platform"),
 		types.Header("Upgrade-Insecure-Requests", `1`),
 		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0.4844.51 Safari/537.36`),
 		types.Header("Accept", `text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.9`),
@@ -59,14 +57,33 @@ func Chrome99() Preset {
 		types.Flag("cert-compression", "brotli"),
 	)
 
-	return Preset{h, f}
+	info := Info{
+		Name:        "chrome99",
+		Browser:     Chrome,
+		Version:     99,
+		FullVersion: "99.0.4844.51",
+		OS:          Windows,
+		Build:       BuildChrome,
+	}
+
+	return Preset{info, h, f, &hints}
 }
 
 func Chrome99Android() Preset {
 	preset := Chrome99()
+	preset.Name = "chrome99_android"
+	preset.FullVersion = "99.0.4844.58"
+	preset.OS = Android
+	preset.Mobile = true
+	preset.SetClientHints(ClientHints{
+		Brand:           GoogleChrome,
+		FullVersion:     preset.FullVersion,
+		Platform:        "Android",
+		PlatformVersion: "12.0.0",
+		Model:           "Pixel 6",
+		Mobile:          true,
+	})
 	preset.SetHeaders(
-		types.Header("sec-ch-ua-mobile", `?1`),
-		types.Header("sec-ch-ua-platform", `"Android"`),
 		types.Header("User-Agent", `Mozilla/5.0 (Linux; Android 12; Pixel 6) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0.4844.58 Mobile Safari/537.36`),
 	)
 
@@ -75,8 +92,11 @@ func Chrome99Android() Preset {
 
 func Chrome100() Preset {
 	preset := Chrome99()
+	preset.Name = "chrome100"
+	preset.Version = 100
+	preset.FullVersion = "100.0.4896.75"
+	preset.SetClientHints(Windows10(GoogleChrome, preset.FullVersion))
 	preset.SetHeaders(
-		types.Header("sec-ch-ua", `" Not A;Brand";v="99", "Chromium";v="100", "Google Chrome";v="100"`),
 		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/100.0.4896.75 Safari/537.36`),
 	)
 
@@ -85,8 +105,11 @@ func Chrome100() Preset {
 
 func Chrome101() Preset {
 	preset := Chrome99()
+	preset.Name = "chrome101"
+	preset.Version = 101
+	preset.FullVersion = "101.0.4951.67"
+	preset.SetClientHints(Windows10(GoogleChrome, preset.FullVersion))
 	preset.SetHeaders(
-		types.Header("sec-ch-ua", `" Not A;Brand";v="99", "Chromium";v="101", "Google Chrome";v="101"`),
 		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/101.0.4951.67 Safari/537.36`),
 	)
 
@@ -95,8 +118,11 @@ func Chrome101() Preset {
 
 func Chrome104() Preset {
 	preset := Chrome99()
+	preset.Name = "chrome104"
+	preset.Version = 104
+	preset.FullVersion = "104.0.5112.81"
+	preset.SetClientHints(Windows10(GoogleChrome, preset.FullVersion))
 	preset.SetHeaders(
-		types.Header("sec-ch-ua", `"Chromium";v="104", " Not A;Brand";v="99", "Google Chrome";v="104"`),
 		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/104.0.0.0 Safari/537.36`),
 	)
 
@@ -105,8 +131,11 @@ func Chrome104() Preset {
 
 func Chrome107() Preset {
 	preset := Chrome99()
+	preset.Name = "chrome107"
+	preset.Version = 107
+	preset.FullVersion = "107.0.5304.107"
+	preset.SetClientHints(Windows10(GoogleChrome, preset.FullVersion))
 	preset.SetHeaders(
-		types.Header("sec-ch-ua", `"Google Chrome";v="107", "Chromium";v="107", "Not=A?Brand";v="24"`),
 		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/107.0.0.0 Safari/537.36`),
 	)
 
@@ -118,8 +147,11 @@ func Chrome107() Preset {
 
 func Chrome110() Preset {
 	preset := Chrome99()
+	preset.Name = "chrome110"
+	preset.Version = 110
+	preset.FullVersion = "110.0.5481.177"
+	preset.SetClientHints(Windows10(GoogleChrome, preset.FullVersion))
 	preset.SetHeaders(
-		types.Header("sec-ch-ua", `"Chromium";v="110", "Not A(Brand";v="24", "Google Chrome";v="110"`),
 		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/110.0.0.0 Safari/537.36`),
 		types.Header("Accept", `text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7`),
 	)
@@ -133,8 +165,11 @@ func Chrome110() Preset {
 
 func Chrome116() Preset {
 	preset := Chrome99()
+	preset.Name = "chrome116"
+	preset.Version = 116
+	preset.FullVersion = "116.0.5845.180"
+	preset.SetClientHints(Windows10(GoogleChrome, preset.FullVersion))
 	preset.SetHeaders(
-		types.Header("sec-ch-ua", `"Chromium";v="116", "Not)A;Brand";v="24", "Google Chrome";v="116"`),
 		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/116.0.0.0 Safari/537.36`),
 		types.Header("Accept", `text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7`),
 	)
@@ -148,8 +183,13 @@ func Chrome116() Preset {
 
 func Edge99() Preset {
 	preset := Chrome99()
+	preset.Name = "edge99"
+	preset.Browser = Edge
+	preset.FullVersion = "99.0.1150.30"
+	hints := Windows10(MicrosoftEdge, preset.FullVersion)
+	hints.ChromiumFullVersion = "99.0.4844.51"
+	preset.SetClientHints(hints)
 	preset.SetHeaders(
-		types.Header("sec-ch-ua", `" Not A;Brand";v="99", "Chromium";v="99", "Microsoft Edge";v="99"`),
 		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0.4844.51 Safari/537.36 Edg/99.0.1150.30`),
 		types.Header("Accept", `text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.9`),
 	)
@@ -158,8 +198,14 @@ func Edge99() Preset {
 
 func Edge101() Preset {
 	preset := Chrome99()
+	preset.Name = "edge101"
+	preset.Browser = Edge
+	preset.Version = 101
+	preset.FullVersion = "101.0.1210.47"
+	hints := Windows10(MicrosoftEdge, preset.FullVersion)
+	hints.ChromiumFullVersion = "101.0.4951.64"
+	preset.SetClientHints(hints)
 	preset.SetHeaders(
-		types.Header("sec-ch-ua", `" Not A;Brand";v="99", "Chromium";v="101", "Microsoft Edge";v="101"`),
 		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/101.0.4951.64 Safari/537.36 Edg/101.0.1210.47`),
 		types.Header("Accept", `text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.9`),
 	)
@@ -184,11 +230,22 @@ func Safari153() Preset {
 		types.Flag("http2-pseudo-headers-order", "mspa"),
 	)
 
-	return Preset{h, f}
+	info := Info{
+		Name:        "safari15_3",
+		Browser:     Safari,
+		Version:     15,
+		FullVersion: "15.3",
+		OS:          MacOS,
+		Build:       BuildChrome,
+	}
+
+	return Preset{info, h, f, nil}
 }
 
 func Safari155() Preset {
 	preset := Safari153()
+	preset.Name = "safari15_5"
+	preset.FullVersion = "15.5"
 	preset.SetHeaders(
 		types.Header("User-Agent", `Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.5 Safari/605.1.15`),
 		types.Header("Accept", `Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.3 Safari/605.1.15`),
@@ -201,3 +258,58 @@ func Safari155() Preset {
 
 	return preset
 }
+
+func Firefox102() Preset {
+	h := types.NewHeaders(
+		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:102.0) Gecko/20100101 Firefox/102.0`),
+		types.Header("Accept", `text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8`),
+		types.Header("Accept-Language", `en-US,en;q=0.5`),
+		types.Header("Accept-Encoding", `gzip, deflate, br`),
+		types.Header("Upgrade-Insecure-Requests", `1`),
+		types.Header("Sec-Fetch-Dest", `document`),
+		types.Header("Sec-Fetch-Mode", `navigate`),
+		types.Header("Sec-Fetch-Site", `none`),
+		types.Header("Sec-Fetch-User", `?1`),
+		types.Header("TE", `Trailers`),
+	)
+	f := types.NewFlags(
+		types.Flag("ciphers", "aes_128_gcm_sha_256,chacha20_poly1305_sha_256,aes_256_gcm_sha_384,ecdhe_ecdsa_aes_128_gcm_sha_256,ecdhe_rsa_aes_128_gcm_sha_256,ecdhe_ecdsa_chacha20_poly1305_sha_256,ecdhe_rsa_chacha20_poly1305_sha_256,ecdhe_ecdsa_aes_256_gcm_sha_384,ecdhe_rsa_aes_256_gcm_sha_384,ecdhe_ecdsa_aes_256_sha,ecdhe_ecdsa_aes_128_sha,ecdhe_rsa_aes_128_sha,ecdhe_rsa_aes_256_sha,rsa_aes_128_gcm_sha_256,rsa_aes_256_gcm_sha_384,rsa_aes_128_sha,rsa_aes_256_sha"),
+		types.Flag("http2", true),
+		types.Flag("compressed", true),
+	)
+
+	info := Info{
+		Name:        "ff102",
+		Browser:     Firefox,
+		Version:     102,
+		FullVersion: "102.0",
+		OS:          Windows,
+		Build:       BuildFirefox,
+	}
+
+	return Preset{info, h, f, nil}
+}
+
+func Firefox109() Preset {
+	preset := Firefox102()
+	preset.Name = "ff109"
+	preset.Version = 109
+	preset.FullVersion = "109.0"
+	preset.SetHeaders(
+		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/109.0`),
+	)
+
+	return preset
+}
+
+func Firefox117() Preset {
+	preset := Firefox102()
+	preset.Name = "ff117"
+	preset.Version = 117
+	preset.FullVersion = "117.0"
+	preset.SetHeaders(
+		types.Header("User-Agent", `Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:109.0) Gecko/20100101 Firefox/117.0`),
+	)
+
+	return preset
+}
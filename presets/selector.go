@@ -0,0 +1,208 @@
+package presets
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+var catalog = []PresetFn{
+	Chrome99Android,
+	Chrome99,
+	Chrome100,
+	Chrome101,
+	Chrome104,
+	Chrome107,
+	Chrome110,
+	Chrome116,
+	Edge99,
+	Edge101,
+	Safari153,
+	Safari155,
+	Firefox102,
+	Firefox109,
+	Firefox117,
+}
+
+// ErrNoPresets is returned when filters of selector do not match any preset
+var ErrNoPresets = errors.New("no presets match selector")
+
+// Selector picks presets matching filters, respecting weights of presets
+type Selector struct {
+	filters    []func(info Info) bool
+	build      Build
+	weight     func(info Info) float64
+	perBrowser bool
+	source     rand.Source
+
+	mu         sync.Mutex
+	rnd        *rand.Rand
+	candidates []PresetFn
+	cumulative []float64
+}
+
+type SelectorOption func(s *Selector)
+
+// ByBrowser keeps presets of any of browsers
+func ByBrowser(browsers ...Browser) SelectorOption {
+	return func(s *Selector) {
+		s.filters = append(s.filters, func(info Info) bool {
+			for _, b := range browsers {
+				if info.Browser == b {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// ByOS keeps presets of any of operating systems
+func ByOS(systems ...OS) SelectorOption {
+	return func(s *Selector) {
+		s.filters = append(s.filters, func(info Info) bool {
+			for _, os := range systems {
+				if info.OS == os {
+					return true
+				}
+			}
+			return false
+		})
+	}
+}
+
+// ByPlatform keeps only desktop or only mobile presets
+func ByPlatform(platform Platform) SelectorOption {
+	return func(s *Selector) {
+		s.filters = append(s.filters, func(info Info) bool {
+			return info.Platform() == platform
+		})
+	}
+}
+
+// ByBuild keeps presets that can be used with build of curl-impersonate, BuildChrome is used by default. Empty build keeps presets of all builds.
+func ByBuild(build Build) SelectorOption {
+	return func(s *Selector) {
+		s.build = build
+	}
+}
+
+// MinVersion keeps presets with major version of browser not less than version
+func MinVersion(version int) SelectorOption {
+	return func(s *Selector) {
+		s.filters = append(s.filters, func(info Info) bool {
+			return info.Version >= version
+		})
+	}
+}
+
+// Weights sets weight of each preset, presets with zero weight are never picked. By default, all presets have same weight.
+func Weights(fn func(info Info) float64) SelectorOption {
+	return func(s *Selector) {
+		s.weight = fn
+		s.perBrowser = false
+	}
+}
+
+// BrowserWeights sets weight of presets per browser (e.g. market share), weight of each browser is split evenly between its presets
+func BrowserWeights(weights map[Browser]float64) SelectorOption {
+	return func(s *Selector) {
+		s.weight = func(info Info) float64 {
+			return weights[info.Browser]
+		}
+		s.perBrowser = true
+	}
+}
+
+// Source sets source of randomness, so same source seeded with same value picks same sequence of presets
+func Source(src rand.Source) SelectorOption {
+	return func(s *Selector) {
+		s.source = src
+	}
+}
+
+// NewSelector returns selector of presets or ErrNoPresets if nothing can be picked with options. Presets are selected for BuildChrome, unless ByBuild is used.
+func NewSelector(options ...SelectorOption) (*Selector, error) {
+	s := &Selector{build: BuildChrome}
+	for _, o := range options {
+		o(s)
+	}
+
+	if s.source != nil {
+		s.rnd = rand.New(s.source)
+	}
+
+	var matched []PresetFn
+	var infos []Info
+	perBrowser := make(map[Browser]int)
+	for _, fn := range catalog {
+		if info := fn().Info; s.match(info) {
+			matched = append(matched, fn)
+			infos = append(infos, info)
+			perBrowser[info.Browser]++
+		}
+	}
+
+	total := 0.0
+	for i, fn := range matched {
+		weight := 1.0
+		if s.weight != nil {
+			weight = s.weight(infos[i])
+			if s.perBrowser {
+				weight /= float64(perBrowser[infos[i].Browser])
+			}
+		}
+
+		if weight > 0 {
+			total += weight
+			s.candidates = append(s.candidates, fn)
+			s.cumulative = append(s.cumulative, total)
+		}
+	}
+
+	if len(s.candidates) == 0 {
+		return nil, ErrNoPresets
+	}
+
+	return s, nil
+}
+
+func (s *Selector) match(info Info) bool {
+	if s.build != "" && info.Build != "" && info.Build != s.build {
+		return false
+	}
+
+	for _, filter := range s.filters {
+		if !filter(info) {
+			return false
+		}
+	}
+	return true
+}
+
+// Next picks next preset. Selector can be used as source of presets, e.g. curl.Preset(selector.Next). Selector that was not created with NewSelector returns Default preset.
+func (s *Selector) Next() Preset {
+	if len(s.candidates) == 0 {
+		return Default()
+	}
+
+	var r float64
+	if s.rnd != nil {
+		s.mu.Lock()
+		r = s.rnd.Float64()
+		s.mu.Unlock()
+	} else {
+		r = rand.Float64()
+	}
+
+	x := r * s.cumulative[len(s.cumulative)-1]
+	i := sort.Search(len(s.cumulative), func(i int) bool {
+		return s.cumulative[i] > x
+	})
+	if i == len(s.candidates) {
+		i--
+	}
+
+	return s.candidates[i]()
+}
@@ -0,0 +1,118 @@
+package presets
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestSelectorReproducible(t *testing.T) {
+	pick := func() []string {
+		s, err := NewSelector(Source(rand.NewSource(42)))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		var result []string
+		for i := 0; i < 20; i++ {
+			result = append(result, s.Next().Get("User-Agent"))
+		}
+		return result
+	}
+
+	first, second := pick(), pick()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Expected same sequence for same source, got difference at %d: %s vs %s", i, first[i], second[i])
+		}
+	}
+}
+
+func TestSelectorFilters(t *testing.T) {
+	s, err := NewSelector(ByBrowser(Chrome), ByPlatform(Desktop), MinVersion(110), Source(rand.NewSource(1)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]bool{
+		Chrome110().Get("User-Agent"): true,
+		Chrome116().Get("User-Agent"): true,
+	}
+	for i := 0; i < 50; i++ {
+		if ua := s.Next().Get("User-Agent"); !expected[ua] {
+			t.Fatalf("Unexpected preset picked: %s", ua)
+		}
+	}
+
+	if _, err := NewSelector(ByBrowser(Safari), ByPlatform(Mobile)); !errors.Is(err, ErrNoPresets) {
+		t.Errorf("Expected ErrNoPresets, got %v", err)
+	}
+}
+
+func TestSelectorWeights(t *testing.T) {
+	s, err := NewSelector(BrowserWeights(map[Browser]float64{Edge: 1}), Source(rand.NewSource(7)))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	edge := map[string]bool{
+		Edge99().Get("User-Agent"):  true,
+		Edge101().Get("User-Agent"): true,
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		ua := s.Next().Get("User-Agent")
+		if !edge[ua] {
+			t.Fatalf("Expected only presets with non-zero weight, got: %s", ua)
+		}
+		seen[ua] = true
+	}
+
+	if len(seen) != len(edge) {
+		t.Errorf("Expected all presets with non-zero weight to be picked, got %d of %d", len(seen), len(edge))
+	}
+
+	if _, err := NewSelector(Weights(func(info Info) float64 { return 0 })); !errors.Is(err, ErrNoPresets) {
+		t.Errorf("Expected ErrNoPresets for zero weights, got %v", err)
+	}
+}
+
+func TestSelectorBuild(t *testing.T) {
+	pick := func(options ...SelectorOption) map[Build]bool {
+		s, err := NewSelector(append(options, Source(rand.NewSource(3)))...)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		builds := make(map[Build]bool)
+		for i := 0; i < 100; i++ {
+			builds[s.Next().Build] = true
+		}
+		return builds
+	}
+
+	if builds := pick(); builds[BuildFirefox] {
+		t.Errorf("Expected presets of chrome build by default, got: %v", builds)
+	}
+
+	if builds := pick(ByBuild(BuildFirefox)); builds[BuildChrome] || !builds[BuildFirefox] {
+		t.Errorf("Expected presets of firefox build, got: %v", builds)
+	}
+
+	if builds := pick(ByBuild("")); !builds[BuildChrome] || !builds[BuildFirefox] {
+		t.Errorf("Expected presets of all builds, got: %v", builds)
+	}
+
+	for i := 0; i < 100; i++ {
+		if Random().Build == BuildFirefox {
+			t.Fatalf("Expected Random to pick presets of chrome build only")
+		}
+	}
+}
+
+func TestSelectorZeroValue(t *testing.T) {
+	var s Selector
+	if p := s.Next(); p.Headers.Len() != 0 || p.Build != "" {
+		t.Errorf("Expected default preset for zero value of selector, got: %+v", p.Info)
+	}
+}
@@ -0,0 +1,395 @@
+package curl
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/plandem/curl-impersonate/types"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TTL of addresses resolved without DNS records, e.g. by hosts of nsswitch, that don't report TTL
+const systemTTL = time.Minute
+
+// Resolver resolves hosts in Go and caches addresses by TTL of DNS records, so each curl process doesn't start with cold DNS cache. Hosts are looked up in StaticHost, then in /etc/hosts, then with resolver of Go that respects search domains and ndots of /etc/resolv.conf.
+type Resolver struct {
+	mu          sync.Mutex
+	nameservers []string
+	next        int
+	static      map[string][]net.IP
+	hosts       map[string][]net.IP
+	cache       map[string]cachedAddrs
+	timeout     time.Duration
+	now         func() time.Time
+}
+
+type cachedAddrs struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+// ResolverOption is an option of Resolver
+type ResolverOption func(r *Resolver) error
+
+// Nameserver adds DNS server, e.g. 1.1.1.1 or 127.0.0.1:5353. Nameservers of /etc/resolv.conf are used if there is no nameserver.
+func Nameserver(addr string) ResolverOption {
+	return func(r *Resolver) error {
+		if _, _, err := net.SplitHostPort(addr); err != nil {
+			addr = net.JoinHostPort(addr, "53")
+		}
+
+		host, _, _ := net.SplitHostPort(addr)
+		if net.ParseIP(host) == nil {
+			return fmt.Errorf("invalid IP address of nameserver %q", addr)
+		}
+
+		r.nameservers = append(r.nameservers, addr)
+		return nil
+	}
+}
+
+// StaticHost resolves host to ips without DNS
+func StaticHost(host string, ips ...string) ResolverOption {
+	return func(r *Resolver) error {
+		if host == "" || len(ips) == 0 {
+			return errors.New("host and at least one IP address are required")
+		}
+
+		var addrs []net.IP
+		for _, ip := range ips {
+			addr := net.ParseIP(ip)
+			if addr == nil {
+				return fmt.Errorf("invalid IP address %q", ip)
+			}
+			addrs = append(addrs, addr)
+		}
+
+		r.static[normalizeHost(host)] = addrs
+		return nil
+	}
+}
+
+// ResolverTimeout sets timeout of lookup of host
+func ResolverTimeout(d time.Duration) ResolverOption {
+	return func(r *Resolver) error {
+		if d <= 0 {
+			return fmt.Errorf("timeout must be positive, got %s", d)
+		}
+		r.timeout = d
+		return nil
+	}
+}
+
+// NewResolver returns resolver with empty cache, /etc/hosts is read once
+func NewResolver(options ...ResolverOption) (*Resolver, error) {
+	r := &Resolver{
+		static:  make(map[string][]net.IP),
+		cache:   make(map[string]cachedAddrs),
+		timeout: 5 * time.Second,
+		now:     time.Now,
+	}
+
+	for _, o := range options {
+		if err := o(r); err != nil {
+			return nil, err
+		}
+	}
+
+	r.hosts = hostsFile("/etc/hosts")
+	return r, nil
+}
+
+// DNS resolves host of request with resolver and passes addresses to curl with --resolve. Hosts of redirects are resolved by curl itself, e.g. with FollowRedirects. Resolver is not used with proxy, DoH, ConnectTo or Resolve for the same host and port.
+func DNS(r *Resolver) Option {
+	return func(curl *Curl) {
+		curl.resolver = r
+	}
+}
+
+// DoH makes curl to resolve hosts with DNS-over-HTTPS server, e.g. https://cloudflare-dns.com/dns-query
+func DoH(serverURL string) Option {
+	return func(curl *Curl) {
+		u, err := url.Parse(serverURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			curl.fail("DoH", "invalid URL of DNS-over-HTTPS server %q", serverURL)
+			return
+		}
+		curl.setFlag("doh-url", serverURL)
+	}
+}
+
+// Resolve returns addresses of host, IPv4 addresses go first
+func (r *Resolver) Resolve(ctx context.Context, host string) ([]net.IP, error) {
+	host = normalizeHost(host)
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+
+	if ips, ok := r.static[host]; ok {
+		return ips, nil
+	}
+
+	if ips, ok := r.hosts[host]; ok {
+		return ips, nil
+	}
+
+	r.mu.Lock()
+	if cached, ok := r.cache[host]; ok && r.now().Before(cached.expires) {
+		r.mu.Unlock()
+		return cached.ips, nil
+	}
+	r.mu.Unlock()
+
+	ips, ttl, err := r.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl > 0 {
+		r.mu.Lock()
+		r.cache[host] = cachedAddrs{ips: ips, expires: r.now().Add(ttl)}
+		r.mu.Unlock()
+	}
+
+	return ips, nil
+}
+
+// lookup returns addresses of host with minimal TTL of records
+func (r *Resolver) lookup(ctx context.Context, host string) ([]net.IP, time.Duration, error) {
+	ttl := &ttlRecorder{}
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network string, address string) (net.Conn, error) {
+			if server := r.nameserver(); server != "" {
+				address = server
+			}
+
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+
+			// resolver of Go sends queries over packet connections without length prefix
+			if udp, ok := conn.(*net.UDPConn); ok {
+				return &ttlPacketConn{UDPConn: udp, ttl: ttl}, nil
+			}
+			return &ttlStreamConn{Conn: conn, ttl: ttl}, nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ips := make([]net.IP, 0, len(addrs))
+	for _, addr := range addrs {
+		ips = append(ips, addr.IP)
+	}
+	ipv4First(ips)
+
+	seconds, ok := ttl.result()
+	if !ok {
+		return ips, systemTTL, nil
+	}
+
+	return ips, time.Duration(seconds) * time.Second, nil
+}
+
+// nameserver returns next nameserver of resolver, empty for nameservers of /etc/resolv.conf
+func (r *Resolver) nameserver() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.nameservers) == 0 {
+		return ""
+	}
+
+	server := r.nameservers[r.next%len(r.nameservers)]
+	r.next++
+	return server
+}
+
+// ttlRecorder keeps minimal TTL of records of answers, that resolver received
+type ttlRecorder struct {
+	mu    sync.Mutex
+	ttl   uint32
+	found bool
+}
+
+func (rec *ttlRecorder) observe(msg []byte) {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return
+	}
+
+	if err := p.SkipAllQuestions(); err != nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	for {
+		h, err := p.AnswerHeader()
+		if err != nil {
+			return
+		}
+
+		switch h.Type {
+		case dnsmessage.TypeA, dnsmessage.TypeAAAA, dnsmessage.TypeCNAME:
+			if !rec.found || h.TTL < rec.ttl {
+				rec.ttl, rec.found = h.TTL, true
+			}
+		}
+
+		if err := p.SkipAnswer(); err != nil {
+			return
+		}
+	}
+}
+
+func (rec *ttlRecorder) result() (uint32, bool) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.ttl, rec.found
+}
+
+// ttlPacketConn records TTL of DNS messages received over UDP
+type ttlPacketConn struct {
+	*net.UDPConn
+	ttl *ttlRecorder
+}
+
+func (c *ttlPacketConn) Read(b []byte) (int, error) {
+	n, err := c.UDPConn.Read(b)
+	if n > 0 {
+		c.ttl.observe(b[:n])
+	}
+	return n, err
+}
+
+// ttlStreamConn records TTL of DNS messages received over TCP, each message has 2 bytes prefix with length
+type ttlStreamConn struct {
+	net.Conn
+	ttl *ttlRecorder
+	buf []byte
+}
+
+func (c *ttlStreamConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.buf = append(c.buf, b[:n]...)
+	for len(c.buf) >= 2 {
+		size := int(binary.BigEndian.Uint16(c.buf))
+		if len(c.buf) < 2+size {
+			break
+		}
+
+		c.ttl.observe(c.buf[2 : 2+size])
+		c.buf = c.buf[2+size:]
+	}
+	return n, err
+}
+
+// withResolve adds --resolve with addresses of host of request, unless curl gets them by itself
+func (curl *Curl) withResolve(ctx context.Context, rawURL string, args []types.Arg) ([]types.Arg, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" || net.ParseIP(u.Hostname()) != nil {
+		return args, nil
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	if resolvedByCurl(args, u.Hostname()+":"+port+":") {
+		return args, nil
+	}
+
+	ips, err := curl.resolver.Resolve(ctx, u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, bracketIP(ip))
+	}
+
+	resolve := u.Hostname() + ":" + port + ":" + strings.Join(addrs, ",")
+	return replaceArgs(args, nil, types.Arg{Name: "resolve", Value: resolve, HasValue: true}), nil
+}
+
+// resolvedByCurl checks if curl gets addresses of host by itself: with proxy, DoH, connect-to or own --resolve
+func resolvedByCurl(args []types.Arg, prefix string) bool {
+	for _, arg := range args {
+		switch arg.Name {
+		case "proxy", "doh-url", "connect-to":
+			return true
+		case "resolve":
+			value := strings.TrimPrefix(arg.Value, "+")
+			if len(value) >= len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hostsFile returns addresses of hosts of hosts file
+func hostsFile(fileName string) map[string][]net.IP {
+	result := make(map[string][]net.IP)
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return result
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			continue
+		}
+
+		for _, name := range fields[1:] {
+			name = normalizeHost(name)
+			result[name] = append(result[name], ip)
+		}
+	}
+
+	for _, ips := range result {
+		ipv4First(ips)
+	}
+	return result
+}
+
+// ipv4First sorts addresses, so IPv4 addresses go first
+func ipv4First(ips []net.IP) {
+	sort.SliceStable(ips, func(i, j int) bool {
+		return ips[i].To4() != nil && ips[j].To4() == nil
+	})
+}
+
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(host, "."))
+}
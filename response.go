@@ -0,0 +1,231 @@
+package curl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/plandem/curl-impersonate/types"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Hop is a response of single step of request, e.g. response of proxy, redirect or final response
+type Hop struct {
+	// Status is a code with reason phrase, e.g. "200 OK"
+	Status     string
+	StatusCode int
+	// Proto is a version of HTTP as it was received, e.g. "HTTP/1.1" or "HTTP/2"
+	Proto      string
+	ProtoMajor int
+	ProtoMinor int
+	// Header with canonical names
+	Header http.Header
+	// RawHeader keeps names of headers as they were received and order of headers
+	RawHeader *types.Headers
+	// Raw is a header block with status line as it was received, e.g. with whitespaces and folded lines, lines are separated with CRLF
+	Raw []byte
+}
+
+// Response is a final response of curl with responses of all hops
+type Response struct {
+	*http.Response
+	// Hops are responses in order they were received, last hop is final response
+	Hops []*Hop
+	// Informational are 1xx responses (e.g. 100 Continue or 103 Early Hints) in order they were received
+	Informational []*Hop
+	// TLSInfo is a state of TLS connection, it is filled with CaptureTLS
+	TLSInfo *TLSInfo
+	body    []byte
+}
+
+// Informational returns true for 1xx responses
+func (h *Hop) Informational() bool {
+	return h.StatusCode >= 100 && h.StatusCode < 200
+}
+
+// EarlyHints returns values of Link headers of all 103 Early Hints responses, e.g. resources to preload
+func (r *Response) EarlyHints() []string {
+	var result []string
+	for _, hop := range r.Informational {
+		if hop.StatusCode == http.StatusEarlyHints {
+			result = append(result, hop.Header.Values("Link")...)
+		}
+	}
+	return result
+}
+
+// Protocol returns version of HTTP of final response, e.g. HTTP2 if HTTP/3 was requested with fallback, but server doesn't support it
+func (r *Response) Protocol() HTTPProtocol {
+	switch {
+	case r.ProtoMajor == 3:
+		return HTTP3
+	case r.ProtoMajor == 2:
+		return HTTP2
+	case r.ProtoMajor == 1 && r.ProtoMinor == 0:
+		return HTTP10
+	}
+	return HTTP11
+}
+
+// Bytes returns body of response, it is same content as Body has, but without extra allocation
+func (r *Response) Bytes() []byte {
+	return r.body
+}
+
+// RawHeader returns headers of final response with names as they were received and in same order
+func (r *Response) RawHeader() *types.Headers {
+	return r.Hops[len(r.Hops)-1].RawHeader
+}
+
+// headers returns non-empty headers of all hops
+func (r *Response) headers() []http.Header {
+	var result []http.Header
+	for _, hop := range r.Hops {
+		if len(hop.Header) > 0 {
+			result = append(result, hop.Header)
+		}
+	}
+	return result
+}
+
+// newResponse returns response for header blocks of all responses and body of final response
+func newResponse(blocks [][]byte, body []byte) (*Response, error) {
+	resp := &Response{body: body}
+	for _, block := range blocks {
+		hop, err := parseHop(block)
+		if err != nil {
+			return nil, err
+		}
+
+		// informational responses are followed by other response, so they are never final
+		if hop.Informational() {
+			resp.Informational = append(resp.Informational, hop)
+		} else {
+			resp.Hops = append(resp.Hops, hop)
+		}
+	}
+
+	if len(resp.Hops) == 0 {
+		return nil, errors.New("unable to extract final HTTP response, only informational responses were received")
+	}
+
+	final := resp.Hops[len(resp.Hops)-1]
+	resp.Response = &http.Response{
+		Status:     final.Status,
+		StatusCode: final.StatusCode,
+		Proto:      final.Proto,
+		ProtoMajor: final.ProtoMajor,
+		ProtoMinor: final.ProtoMinor,
+		Header:     final.Header,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+
+	if final.StatusCode >= 400 {
+		reason := strings.TrimSpace(strings.TrimPrefix(final.Status, strconv.Itoa(final.StatusCode)))
+		status := http.StatusText(final.StatusCode)
+		if status == "" {
+			// non-standard codes like 520 have only reason phrase that was received
+			status = reason
+		}
+		if status == "" {
+			status = "Unknown Status"
+		}
+
+		return resp, &HTTPError{
+			StatusCode: final.StatusCode,
+			Status:     status,
+			Reason:     reason,
+		}
+	}
+
+	return resp, nil
+}
+
+// parseRawHeaders returns headers of block in order and with names as they were received
+func parseRawHeaders(headers []byte) *types.Headers {
+	result := types.NewHeaders()
+	for _, field := range headerFields(headers) {
+		result.Add(field[0], field[1])
+	}
+	return result
+}
+
+// headerFields returns names and values of headers of block, lines of obsolete line folding are joined to value of previous header with space as RFC 9112 requires
+func headerFields(headers []byte) [][2]string {
+	var result [][2]string
+	lines := bytes.Split(headers, []byte("\r\n"))
+	// first line is a status line, reason phrase can have colon
+	for _, line := range lines[1:] {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			if len(result) > 0 {
+				last := &result[len(result)-1]
+				last[1] = strings.TrimSpace(last[1] + " " + strings.TrimSpace(string(line)))
+			}
+			continue
+		}
+
+		if k, v, ok := bytes.Cut(line, []byte(":")); ok {
+			result = append(result, [2]string{strings.TrimSpace(string(k)), strings.TrimSpace(string(v))})
+		}
+	}
+	return result
+}
+
+// parseStatusLine parses status line of HTTP/1.x, HTTP/2 or HTTP/3 response, e.g. "HTTP/1.1 404 Not Found" or "HTTP/2 200"
+func parseStatusLine(line string) (*Hop, error) {
+	proto, rest, _ := strings.Cut(line, " ")
+	major, minor, ok := parseHTTPVersion(proto)
+	if !ok {
+		return nil, fmt.Errorf("malformed status line %q: invalid version of HTTP %q", line, proto)
+	}
+
+	code, reason, _ := strings.Cut(strings.TrimLeft(rest, " "), " ")
+	statusCode, err := strconv.Atoi(code)
+	if len(code) != 3 || err != nil || statusCode < 100 {
+		return nil, fmt.Errorf("malformed status line %q: invalid status code %q", line, code)
+	}
+
+	// HTTP/2 and HTTP/3 have no reason phrase
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		reason = http.StatusText(statusCode)
+	}
+
+	status := code
+	if reason != "" {
+		status += " " + reason
+	}
+
+	return &Hop{
+		Status:     status,
+		StatusCode: statusCode,
+		Proto:      proto,
+		ProtoMajor: major,
+		ProtoMinor: minor,
+	}, nil
+}
+
+// parseHTTPVersion parses HTTP/1.1 as well as HTTP/2 and HTTP/3 without minor version
+func parseHTTPVersion(proto string) (int, int, bool) {
+	version := strings.TrimPrefix(proto, "HTTP/")
+	if version == proto || version == "" {
+		return 0, 0, false
+	}
+
+	majorPart, minorPart, hasMinor := strings.Cut(version, ".")
+	major, err := strconv.Atoi(majorPart)
+	if err != nil || len(majorPart) != 1 {
+		return 0, 0, false
+	}
+
+	minor := 0
+	if hasMinor {
+		if minor, err = strconv.Atoi(minorPart); err != nil || len(minorPart) != 1 {
+			return 0, 0, false
+		}
+	}
+
+	return major, minor, true
+}
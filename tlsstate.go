@@ -0,0 +1,167 @@
+package curl
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/plandem/curl-impersonate/types"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	tlsStartMarker = "@@curl-tls-start@@"
+	tlsEndMarker   = "@@curl-tls-end@@"
+)
+
+// write-out of curl with result of verification and certificates of server, it goes to stderr, so output is not changed
+var tlsWriteOut = "%{stderr}\n" + tlsStartMarker + "%{ssl_verify_result}\n%{certs}\n" + tlsEndMarker + "\n"
+
+// TLSInfo is a state of TLS connection of final response as curl reports it
+type TLSInfo struct {
+	// Version of TLS, e.g. TLSv1.3
+	Version string
+	// Cipher is a name of cipher suite as TLS library reports it, e.g. TLS_AES_128_GCM_SHA256 or ECDHE-RSA-AES128-GCM-SHA256
+	Cipher string
+	// ALPN is a protocol that server accepted, e.g. h2 or http/1.1
+	ALPN string
+	// VerifyResult is a result of verification of certificate, 0 if certificate is valid
+	VerifyResult int
+	// Certificates of server, first certificate is certificate of server
+	Certificates []*x509.Certificate
+}
+
+// CaptureTLS makes curl to report state of TLS connection, so Response.TLS and Response.TLSInfo are filled for HTTPS. It requires curl 7.88 or newer for certificates.
+// State is reported with --verbose and --write-out, so it can't be used with --write-out. Verbose output of curl has all headers of request, including Cookie and Authorization, it is kept in memory only while response is parsed.
+func CaptureTLS() Option {
+	return func(curl *Curl) {
+		curl.captureTLS = true
+	}
+}
+
+// tls versions as curl reports them
+var tlsVersions = map[string]uint16{
+	"TLSv1":   tls.VersionTLS10,
+	"TLSv1.0": tls.VersionTLS10,
+	"TLSv1.1": tls.VersionTLS11,
+	"TLSv1.2": tls.VersionTLS12,
+	"TLSv1.3": tls.VersionTLS13,
+}
+
+// OpenSSL names of TLS 1.2 cipher suites, TLS 1.3 and NSS use IANA names
+var opensslCiphers = map[string]string{
+	"ECDHE-ECDSA-AES128-GCM-SHA256": "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256",
+	"ECDHE-RSA-AES128-GCM-SHA256":   "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256",
+	"ECDHE-ECDSA-AES256-GCM-SHA384": "TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384",
+	"ECDHE-RSA-AES256-GCM-SHA384":   "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+	"ECDHE-ECDSA-CHACHA20-POLY1305": "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256",
+	"ECDHE-RSA-CHACHA20-POLY1305":   "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256",
+	"ECDHE-ECDSA-AES128-SHA":        "TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA",
+	"ECDHE-ECDSA-AES256-SHA":        "TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA",
+	"ECDHE-RSA-AES128-SHA":          "TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA",
+	"ECDHE-RSA-AES256-SHA":          "TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA",
+	"AES128-GCM-SHA256":             "TLS_RSA_WITH_AES_128_GCM_SHA256",
+	"AES256-GCM-SHA384":             "TLS_RSA_WITH_AES_256_GCM_SHA384",
+	"AES128-SHA":                    "TLS_RSA_WITH_AES_128_CBC_SHA",
+	"AES256-SHA":                    "TLS_RSA_WITH_AES_256_CBC_SHA",
+}
+
+// withTLSCapture adds --verbose and --write-out, that report state of TLS to stderr
+func withTLSCapture(args []types.Arg) ([]types.Arg, error) {
+	for _, arg := range args {
+		if arg.Name == "write-out" {
+			return nil, &OptionError{Option: "CaptureTLS", Reason: "--write-out can't be used, it reports state of TLS"}
+		}
+	}
+
+	return replaceArgs(args, []string{"verbose"},
+		types.Arg{Name: "verbose"},
+		types.Arg{Name: "write-out", Value: tlsWriteOut, HasValue: true},
+	), nil
+}
+
+// parseTLSInfo parses verbose output and write-out of curl, nil is returned if connection has no TLS
+func parseTLSInfo(stderr []byte) *TLSInfo {
+	info := &TLSInfo{}
+	verbose := stderr
+	if start := bytes.LastIndex(stderr, []byte(tlsStartMarker)); start != -1 {
+		verbose = stderr[:start]
+		writeOut := stderr[start+len(tlsStartMarker):]
+		if end := bytes.Index(writeOut, []byte(tlsEndMarker)); end != -1 {
+			writeOut = writeOut[:end]
+		}
+
+		result, certs, _ := bytes.Cut(writeOut, []byte("\n"))
+		info.VerifyResult, _ = strconv.Atoi(string(bytes.TrimSpace(result)))
+
+		for {
+			var block *pem.Block
+			block, certs = pem.Decode(certs)
+			if block == nil {
+				break
+			}
+
+			if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+				info.Certificates = append(info.Certificates, cert)
+			}
+		}
+	}
+
+	// connection of final response is reported last, e.g. after redirects
+	for _, line := range strings.Split(string(verbose), "\n") {
+		line = strings.TrimSpace(strings.TrimPrefix(line, "*"))
+		switch {
+		case strings.HasPrefix(line, "SSL connection using "):
+			// e.g. TLSv1.3 / TLS_AES_256_GCM_SHA384 / X25519 / id-ecPublicKey
+			parts := strings.Split(strings.TrimPrefix(line, "SSL connection using "), " / ")
+			info.Version = strings.TrimSpace(parts[0])
+			info.Cipher, info.ALPN = "", ""
+			if len(parts) > 1 {
+				info.Cipher = strings.TrimSpace(parts[1])
+			}
+		case strings.HasPrefix(line, "ALPN: server accepted "):
+			info.ALPN = strings.TrimSpace(strings.TrimPrefix(line, "ALPN: server accepted "))
+		case strings.HasPrefix(line, "ALPN, server accepted to use "):
+			info.ALPN = strings.TrimSpace(strings.TrimPrefix(line, "ALPN, server accepted to use "))
+		}
+	}
+
+	if info.Version == "" && len(info.Certificates) == 0 {
+		return nil
+	}
+
+	return info
+}
+
+// ConnectionState returns state of connection in format of crypto/tls
+func (info *TLSInfo) ConnectionState(rawURL string) *tls.ConnectionState {
+	state := &tls.ConnectionState{
+		Version:            tlsVersions[info.Version],
+		HandshakeComplete:  true,
+		CipherSuite:        cipherSuiteID(info.Cipher),
+		NegotiatedProtocol: info.ALPN,
+		PeerCertificates:   info.Certificates,
+	}
+
+	if u, err := url.Parse(rawURL); err == nil {
+		state.ServerName = u.Hostname()
+	}
+
+	return state
+}
+
+// cipherSuiteID returns ID of cipher suite by IANA or OpenSSL name, 0 for unknown names
+func cipherSuiteID(name string) uint16 {
+	if iana, ok := opensslCiphers[name]; ok {
+		name = iana
+	}
+
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID
+		}
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package types
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Arg is a long option of curl (without leading dashes) with optional value
+type Arg struct {
+	Name     string
+	Value    string
+	HasValue bool
+}
+
+// CommandLine returns arguments for command line of curl
+func CommandLine(args []Arg) []string {
+	var result []string
+	for _, arg := range args {
+		result = append(result, "--"+arg.Name)
+		if arg.HasValue {
+			result = append(result, arg.Value)
+		}
+	}
+	return result
+}
+
+var configEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\t", `\t`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\v", `\v`,
+)
+
+// Config returns config document of curl (see --config) with same options as CommandLine. Every value is quoted, so spaces, quotes and line breaks are kept as is.
+func Config(args []Arg) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, arg := range args {
+		if strings.ContainsAny(arg.Name, " \t\r\n=:\"\x00") {
+			return nil, fmt.Errorf("invalid name of option %q", arg.Name)
+		}
+
+		buf.WriteString(arg.Name)
+		if arg.HasValue {
+			if strings.ContainsRune(arg.Value, 0) {
+				return nil, fmt.Errorf("value of option --%s contains NUL", arg.Name)
+			}
+
+			buf.WriteString(` = "`)
+			buf.WriteString(configEscaper.Replace(arg.Value))
+			buf.WriteByte('"')
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,36 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestConfig(t *testing.T) {
+	args := []Arg{
+		{Name: "include"},
+		{Name: "header", Value: `X-Quote: "a" \ b`, HasValue: true},
+		{Name: "data", Value: "line\r\nnext\ttab", HasValue: true},
+		{Name: "url", Value: "http://localhost/?a=1&b=2", HasValue: true},
+	}
+
+	expected := "include\n" +
+		`header = "X-Quote: \"a\" \\ b"` + "\n" +
+		`data = "line\r\nnext\ttab"` + "\n" +
+		`url = "http://localhost/?a=1&b=2"` + "\n"
+
+	got, err := Config(args)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(got) != expected {
+		t.Errorf("Unexpected config\nExpected: %q\nGot:      %q", expected, got)
+	}
+
+	if got := CommandLine(args); !reflect.DeepEqual(got, []string{"--include", "--header", `X-Quote: "a" \ b`, "--data", "line\r\nnext\ttab", "--url", "http://localhost/?a=1&b=2"}) {
+		t.Errorf("Unexpected arguments: %q", got)
+	}
+
+	if _, err := Config([]Arg{{Name: "data", Value: "a\x00b", HasValue: true}}); err == nil {
+		t.Errorf("Expected error for NUL in value")
+	}
+}
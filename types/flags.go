@@ -1,23 +1,81 @@
 package types
 
-import "fmt"
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"time"
+)
 
+// Flags that preserve order and support flags repeated few times (e.g. --resolve or --cookie)
 type Flags struct {
-	m map[string]interface{}
+	list []flag
+}
+
+type flag struct {
+	name  string
+	value interface{}
+	// added is true for values added with Add, such values are accumulated between layers by MergeFlags
+	added bool
+	// unset is true for flags removed with Unset, such flags are removed from lower layers by MergeFlags
+	unset bool
 }
 
 type flagValue func(f *Flags)
 
+// flags that curl accepts few times, other flags have only one value
+var repeatableFlags = map[string]bool{
+	"header":         true,
+	"proxy-header":   true,
+	"resolve":        true,
+	"connect-to":     true,
+	"cookie":         true,
+	"form":           true,
+	"form-string":    true,
+	"data":           true,
+	"data-ascii":     true,
+	"data-binary":    true,
+	"data-raw":       true,
+	"data-urlencode": true,
+	"json":           true,
+	"url-query":      true,
+	"quote":          true,
+	"mail-rcpt":      true,
+	"telnet-option":  true,
+	"variable":       true,
+}
+
+// groups of mutually exclusive flags, only one flag of group can be used
+var exclusiveFlags = [][]string{
+	{"tlsv1", "tlsv1.0", "tlsv1.1", "tlsv1.2", "tlsv1.3"},
+	{"http1.0", "http1.1", "http2", "http2-prior-knowledge", "http3", "http3-only"},
+	{"ipv4", "ipv6"},
+}
+
+// Flag sets single value of flag
 func Flag(name string, v interface{}) func(flags *Flags) {
 	return func(flags *Flags) {
-		flags.m[name] = v
+		flags.Set(name, v)
 	}
 }
 
-func NewFlags(values ...flagValue) *Flags {
-	f := &Flags{
-		m: make(map[string]interface{}),
+// RepeatedFlag adds one more value of flag
+func RepeatedFlag(name string, v interface{}) func(flags *Flags) {
+	return func(flags *Flags) {
+		flags.Add(name, v)
+	}
+}
+
+// UnsetFlag removes flag from lower layers during merge
+func UnsetFlag(name string) func(flags *Flags) {
+	return func(flags *Flags) {
+		flags.Unset(name)
 	}
+}
+
+func NewFlags(values ...flagValue) *Flags {
+	f := &Flags{}
 	f.SetFlags(values...)
 	return f
 }
@@ -28,30 +86,228 @@ func (f *Flags) SetFlags(values ...flagValue) {
 	}
 }
 
+// Set replaces all values of flag with single value, flag keeps position of first value
 func (f *Flags) Set(name string, v interface{}) {
-	f.m[name] = v
+	f.replace(flag{name: name, value: v})
 }
 
-func (f *Flags) Generate() []string {
+// Add adds value of flag after all other flags
+func (f *Flags) Add(name string, v interface{}) {
+	f.list = append(f.list, flag{name: name, value: v, added: true})
+}
+
+// Del removes all values of flag
+func (f *Flags) Del(name string) {
+	list := f.list[:0]
+	for _, fl := range f.list {
+		if fl.name != name {
+			list = append(list, fl)
+		}
+	}
+	f.list = list
+}
+
+// Unset removes all values of flag and marks it as removed, so MergeFlags removes it from lower layers too
+func (f *Flags) Unset(name string) {
+	f.replace(flag{name: name, unset: true})
+}
+
+func (f *Flags) replace(v flag) {
+	found := false
+	list := f.list[:0]
+	for _, fl := range f.list {
+		if fl.name == v.name {
+			if found {
+				continue
+			}
+			fl = v
+			found = true
+		}
+		list = append(list, fl)
+	}
+
+	f.list = list
+	if !found {
+		f.list = append(f.list, v)
+	}
+}
+
+// Clone returns copy of flags
+func (f *Flags) Clone() *Flags {
+	return &Flags{list: append([]flag(nil), f.list...)}
+}
+
+// Lookup returns last value of flag and true if flag was set
+func (f *Flags) Lookup(name string) (interface{}, bool) {
+	for i := len(f.list) - 1; i >= 0; i-- {
+		if f.list[i].name == name {
+			return f.list[i].value, !f.list[i].unset
+		}
+	}
+	return nil, false
+}
+
+// Values returns all values of flag in order they were added
+func (f *Flags) Values(name string) []interface{} {
+	var result []interface{}
+	for _, fl := range f.list {
+		if fl.name == name && !fl.unset {
+			result = append(result, fl.value)
+		}
+	}
+	return result
+}
+
+// Names returns names of flags in order they were added, repeated flags are listed once
+func (f *Flags) Names() []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, fl := range f.list {
+		if !fl.unset && !seen[fl.name] {
+			seen[fl.name] = true
+			result = append(result, fl.name)
+		}
+	}
+	return result
+}
+
+// MergeFlags resolves layers of flags by names, where each next layer has higher priority. Values of repeatable flags added with Add are accumulated, other flags replace values of lower layers in place. Setting flag of exclusive group (e.g. --tlsv1.3) removes other flags of group (e.g. --tlsv1.2) and Unset removes flag from lower layers.
+func MergeFlags(layers ...*Flags) *Flags {
+	result := NewFlags()
+	for _, layer := range layers {
+		if layer == nil {
+			continue
+		}
+
+		for _, fl := range layer.list {
+			switch {
+			case fl.unset:
+				result.Del(fl.name)
+			case fl.added && repeatableFlags[fl.name]:
+				result.list = append(result.list, fl)
+			default:
+				if fl.value != false {
+					for _, name := range exclusiveWith(fl.name) {
+						result.Del(name)
+					}
+				}
+
+				fl.added = false
+				result.replace(fl)
+			}
+		}
+	}
+
+	return result
+}
+
+// exclusiveWith returns flags that can't be used together with flag
+func exclusiveWith(name string) []string {
 	var result []string
-	for k, v := range f.m {
-		switch v.(type) {
+	for _, group := range exclusiveFlags {
+		for _, member := range group {
+			if member == name {
+				for _, other := range group {
+					if other != name {
+						result = append(result, other)
+					}
+				}
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Args returns options of curl, error is returned for values of unsupported types and nil values of pointer types
+func (f *Flags) Args() ([]Arg, error) {
+	return f.args(true)
+}
+
+// args returns options of curl, flags with invalid values are skipped unless strict is set
+func (f *Flags) args(strict bool) ([]Arg, error) {
+	var result []Arg
+	for _, fl := range f.list {
+		if fl.unset {
+			continue
+		}
+
+		arg := Arg{Name: fl.name, HasValue: true}
+		switch v := fl.value.(type) {
 		case nil:
-			result = append(result, fmt.Sprintf("--%s", k))
+			arg.HasValue = false
 		case bool:
-			if v == true {
-				result = append(result, fmt.Sprintf("--%s", k))
+			if !v {
+				continue
 			}
+			arg.HasValue = false
+		case time.Duration:
+			// curl accepts time in seconds with fraction
+			arg.Value = strconv.FormatFloat(v.Seconds(), 'f', -1, 64)
 		case int:
-			result = append(result, fmt.Sprintf("--%s", k), fmt.Sprintf("%d", v))
+			arg.Value = strconv.FormatInt(int64(v), 10)
+		case int64:
+			arg.Value = strconv.FormatInt(v, 10)
+		case int32:
+			arg.Value = strconv.FormatInt(int64(v), 10)
+		case uint:
+			arg.Value = strconv.FormatUint(uint64(v), 10)
+		case uint64:
+			arg.Value = strconv.FormatUint(v, 10)
+		case uint32:
+			arg.Value = strconv.FormatUint(uint64(v), 10)
 		case float64:
-			result = append(result, fmt.Sprintf("--%s", k), fmt.Sprintf("%.4f", v))
+			arg.Value = strconv.FormatFloat(v, 'f', -1, 64)
+		case float32:
+			arg.Value = strconv.FormatFloat(float64(v), 'f', -1, 32)
 		case string:
-			result = append(result, fmt.Sprintf("--%s", k), v.(string))
+			arg.Value = v
+		case []string:
+			for _, item := range v {
+				result = append(result, Arg{Name: fl.name, Value: item, HasValue: true})
+			}
+			continue
+		case *url.URL:
+			if v == nil {
+				if strict {
+					return nil, fmt.Errorf("nil value of flag --%s", fl.name)
+				}
+				continue
+			}
+			arg.Value = v.String()
+		case fmt.Stringer:
+			if isNil(v) {
+				if strict {
+					return nil, fmt.Errorf("nil value of flag --%s", fl.name)
+				}
+				continue
+			}
+			arg.Value = v.String()
 		default:
-			panic("Unsupported type of flag")
+			if strict {
+				return nil, fmt.Errorf("unsupported type %T of flag --%s", v, fl.name)
+			}
+			continue
 		}
+
+		result = append(result, arg)
 	}
 
-	return result
+	return result, nil
+}
+
+// Generate returns arguments for curl, flags with values of unsupported types or nil values are skipped, use Args to get error for them
+func (f *Flags) Generate() []string {
+	args, _ := f.args(false)
+	return CommandLine(args)
+}
+
+// isNil reports if value is a typed nil, e.g. nil pointer that implements fmt.Stringer
+func isNil(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
 }
@@ -0,0 +1,149 @@
+package types
+
+import (
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlagsOrder(t *testing.T) {
+	f := NewFlags(
+		Flag("silent", true),
+		RepeatedFlag("resolve", "example.com:443:127.0.0.1"),
+		Flag("max-time", 10),
+		RepeatedFlag("resolve", "example.org:443:127.0.0.2"),
+		Flag("compressed", true),
+	)
+	f.Set("max-time", 20)
+
+	expected := []string{
+		"--silent",
+		"--resolve", "example.com:443:127.0.0.1",
+		"--max-time", "20",
+		"--resolve", "example.org:443:127.0.0.2",
+		"--compressed",
+	}
+	for i := 0; i < 10; i++ {
+		if got := f.Generate(); !reflect.DeepEqual(got, expected) {
+			t.Fatalf("Unexpected arguments\nExpected: %q\nGot:      %q", expected, got)
+		}
+	}
+
+	if values := f.Values("resolve"); len(values) != 2 {
+		t.Errorf("Expected 2 values of repeated flag, got %v", values)
+	}
+
+	f.Set("resolve", "example.net:80:127.0.0.3")
+	expected = []string{
+		"--silent",
+		"--resolve", "example.net:80:127.0.0.3",
+		"--max-time", "20",
+		"--compressed",
+	}
+	if got := f.Generate(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected Set to replace all values in place\nExpected: %q\nGot:      %q", expected, got)
+	}
+
+	if v, ok := f.Lookup("max-time"); !ok || v != 20 {
+		t.Errorf("Expected max-time to be 20, got %v", v)
+	}
+}
+
+func TestFlagsTypes(t *testing.T) {
+	u, _ := url.Parse("socks5h://127.0.0.1:1080")
+	f := NewFlags(
+		Flag("max-time", 1500*time.Millisecond),
+		Flag("limit-rate", int64(1024)),
+		Flag("max-redirs", uint(5)),
+		Flag("expect100-timeout", 0.25),
+		Flag("proxy", u),
+		Flag("connect-to", []string{"a.com:443:b.com:443", "c.com:443:d.com:443"}),
+		Flag("verbose", false),
+		Flag("retry", nil),
+	)
+
+	expected := []string{
+		"--max-time", "1.5",
+		"--limit-rate", "1024",
+		"--max-redirs", "5",
+		"--expect100-timeout", "0.25",
+		"--proxy", "socks5h://127.0.0.1:1080",
+		"--connect-to", "a.com:443:b.com:443",
+		"--connect-to", "c.com:443:d.com:443",
+		"--retry",
+	}
+	args, err := f.Args()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := CommandLine(args); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected arguments\nExpected: %q\nGot:      %q", expected, got)
+	}
+
+	for _, v := range []interface{}{map[string]string{}, (*url.URL)(nil), (*net.IPNet)(nil)} {
+		invalid := f.Clone()
+		invalid.Set("proxy", v)
+		if _, err := invalid.Args(); err == nil {
+			t.Errorf("Expected an error for value %#v of flag", v)
+		}
+
+		// Generate skips invalid flags
+		if got := invalid.Generate(); len(got) != len(expected)-2 {
+			t.Errorf("Expected invalid flag to be skipped, got: %q", got)
+		}
+	}
+}
+
+func TestMergeFlags(t *testing.T) {
+	preset := NewFlags(
+		Flag("ciphers", "TLS_AES_128_GCM_SHA256"),
+		Flag("http2", true),
+		Flag("compressed", true),
+		Flag("tlsv1.2", true),
+		RepeatedFlag("resolve", "example.com:443:127.0.0.1"),
+	)
+	client := NewFlags(
+		Flag("tlsv1.3", true),
+		UnsetFlag("compressed"),
+		RepeatedFlag("resolve", "example.org:443:127.0.0.2"),
+		Flag("max-time", 10),
+		Flag("max-time", 20),
+	)
+	request := NewFlags(
+		Flag("http1.1", true),
+		Flag("max-time", 5),
+	)
+
+	expected := []string{
+		"--ciphers", "TLS_AES_128_GCM_SHA256",
+		"--resolve", "example.com:443:127.0.0.1",
+		"--tlsv1.3",
+		"--resolve", "example.org:443:127.0.0.2",
+		"--max-time", "5",
+		"--http1.1",
+	}
+	got := MergeFlags(preset, client, request).Generate()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected merged arguments\nExpected: %q\nGot:      %q", expected, got)
+	}
+
+	request.Set("resolve", "example.net:443:127.0.0.3")
+	request.Del("http1.1")
+	expected = []string{
+		"--ciphers", "TLS_AES_128_GCM_SHA256",
+		"--http2",
+		"--resolve", "example.net:443:127.0.0.3",
+		"--tlsv1.3",
+		"--max-time", "5",
+	}
+	got = MergeFlags(preset, client, request).Generate()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected single-value flag to replace repeated values\nExpected: %q\nGot:      %q", expected, got)
+	}
+
+	if names := preset.Names(); len(names) != 5 {
+		t.Errorf("Expected layers to be unchanged after merge, got %v", names)
+	}
+}
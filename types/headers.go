@@ -1,11 +1,23 @@
 package types
 
-import "fmt"
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+)
 
-// Headers that preserve case and order
+// Headers that preserve case and order, names are matched case-insensitively and header can have few values
 type Headers struct {
-	m    map[string]string
-	keys []string
+	list []header
+}
+
+type header struct {
+	name  string
+	value string
+	// removed headers are sent as `Name:`, so curl doesn't send own default header
+	removed bool
 }
 
 type headerValue func(h *Headers)
@@ -16,43 +28,351 @@ func Header(k string, v string) func(headers *Headers) {
 	}
 }
 
-func NewHeaders(values ...headerValue) *Headers {
-	headers := &Headers{
-		m:    map[string]string{},
-		keys: []string{},
+// RepeatedHeader adds one more value of header
+func RepeatedHeader(k string, v string) func(headers *Headers) {
+	return func(headers *Headers) {
+		headers.Add(k, v)
+	}
+}
+
+// RemoveHeader removes default header of curl, e.g. Accept or User-Agent
+func RemoveHeader(k string) func(headers *Headers) {
+	return func(headers *Headers) {
+		headers.Remove(k)
 	}
+}
+
+func NewHeaders(values ...headerValue) *Headers {
+	headers := &Headers{}
 	headers.SetHeaders(values...)
 	return headers
 }
 
+// FromHTTPHeader returns headers with values of http.Header, names are sorted because order of http.Header is unknown
+func FromHTTPHeader(src http.Header) *Headers {
+	names := make([]string, 0, len(src))
+	for k := range src {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	h := NewHeaders()
+	for _, k := range names {
+		for _, v := range src[k] {
+			h.Add(k, v)
+		}
+	}
+	return h
+}
+
 func (h *Headers) SetHeaders(values ...headerValue) {
 	for _, o := range values {
 		o(h)
 	}
 }
 
+// Set replaces all values of header with single value, header keeps case of name and position of first value
 func (h *Headers) Set(k string, v string) {
-	_, present := h.m[k]
-	h.m[k] = v
-	if !present {
-		h.keys = append(h.keys, k)
+	h.replace(header{name: k, value: v})
+}
+
+// Add adds value of header after all other headers
+func (h *Headers) Add(k string, v string) {
+	for i, e := range h.list {
+		if strings.EqualFold(e.name, k) && e.removed {
+			// value replaces header that was removed
+			h.list[i] = header{name: e.name, value: v}
+			return
+		}
+	}
+	h.list = append(h.list, header{name: k, value: v})
+}
+
+// Del removes all values of header
+func (h *Headers) Del(k string) {
+	list := h.list[:0]
+	for _, e := range h.list {
+		if !strings.EqualFold(e.name, k) {
+			list = append(list, e)
+		}
+	}
+	h.list = list
+}
+
+// Remove marks header as removed, so curl doesn't send it even if it is default header of curl
+func (h *Headers) Remove(k string) {
+	h.replace(header{name: k, removed: true})
+}
+
+func (h *Headers) replace(v header) {
+	found := false
+	list := h.list[:0]
+	for _, e := range h.list {
+		if strings.EqualFold(e.name, v.name) {
+			if found {
+				continue
+			}
+			v.name = e.name
+			e = v
+			found = true
+		}
+		list = append(list, e)
+	}
+
+	h.list = list
+	if !found {
+		h.list = append(h.list, v)
+	}
+}
+
+// IsRemoved returns true if header was removed with Remove
+func (h *Headers) IsRemoved(k string) bool {
+	for _, e := range h.list {
+		if strings.EqualFold(e.name, k) {
+			return e.removed
+		}
 	}
+	return false
 }
 
+// Get returns first value of header
 func (h *Headers) Get(k string) string {
-	return h.m[k]
+	for _, e := range h.list {
+		if strings.EqualFold(e.name, k) && !e.removed {
+			return e.value
+		}
+	}
+	return ""
 }
 
-func (h *Headers) Generate(shuffle bool) []string {
+// Values returns all values of header in order they were added
+func (h *Headers) Values(k string) []string {
+	var result []string
+	for _, e := range h.list {
+		if strings.EqualFold(e.name, k) && !e.removed {
+			result = append(result, e.value)
+		}
+	}
+	return result
+}
+
+// Has returns true if header has at least one value
+func (h *Headers) Has(k string) bool {
+	for _, e := range h.list {
+		if strings.EqualFold(e.name, k) && !e.removed {
+			return true
+		}
+	}
+	return false
+}
+
+// Len returns number of values of all headers
+func (h *Headers) Len() int {
+	n := 0
+	for _, e := range h.list {
+		if !e.removed {
+			n++
+		}
+	}
+	return n
+}
+
+// Range calls fn for each value of headers in order they were added, until fn returns false
+func (h *Headers) Range(fn func(k string, v string) bool) {
+	for _, e := range h.list {
+		if !e.removed && !fn(e.name, e.value) {
+			return
+		}
+	}
+}
+
+// Clone returns copy of headers
+func (h *Headers) Clone() *Headers {
+	return &Headers{list: append([]header(nil), h.list...)}
+}
+
+// Keys returns names of headers in order they were added, names of repeated headers are listed once
+func (h *Headers) Keys() []string {
 	var result []string
+	for _, e := range h.list {
+		if !containsFold(result, e.name) {
+			result = append(result, e.name)
+		}
+	}
+	return result
+}
+
+// HTTPHeader returns copy of headers as http.Header, removed headers are skipped
+func (h *Headers) HTTPHeader() http.Header {
+	result := make(http.Header)
+	h.Range(func(k string, v string) bool {
+		result.Add(k, v)
+		return true
+	})
+	return result
+}
+
+func containsFold(names []string, k string) bool {
+	for _, name := range names {
+		if strings.EqualFold(name, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Args returns options of curl to send headers exactly as they are, error is returned for invalid names or values that could inject other headers
+func (h *Headers) Args(shuffle bool) ([]Arg, error) {
+	return h.args(shuffle, true)
+}
+
+// args returns options of curl for headers, invalid headers are skipped unless strict is set
+func (h *Headers) args(shuffle bool, strict bool) ([]Arg, error) {
+	list := h.list
 	if shuffle {
-		for k, v := range h.m {
-			result = append(result, "-H", fmt.Sprintf("'%s: %s'", k, v))
+		// shuffle names, but keep order of values of same header
+		keys := h.Keys()
+		rand.Shuffle(len(keys), func(i, j int) {
+			keys[i], keys[j] = keys[j], keys[i]
+		})
+
+		list = make([]header, 0, len(h.list))
+		for _, k := range keys {
+			for _, e := range h.list {
+				if strings.EqualFold(e.name, k) {
+					list = append(list, e)
+				}
+			}
+		}
+	}
+
+	var result []Arg
+	for _, e := range list {
+		v, err := e.arg()
+		if err != nil {
+			if strict {
+				return nil, err
+			}
+			continue
+		}
+		result = append(result, Arg{Name: "header", Value: v, HasValue: true})
+	}
+	return result, nil
+}
+
+// arg returns header in format of curl: `Name: value`, `Name;` for empty value and `Name:` for removed header
+func (e header) arg() (string, error) {
+	k, v := e.name, e.value
+	if !ValidHeaderName(k) {
+		return "", fmt.Errorf("invalid name of header %q", k)
+	}
+
+	if e.removed {
+		return k + ":", nil
+	}
+
+	if !ValidHeaderValue(v) {
+		return "", fmt.Errorf("invalid value of header %s: %q", k, v)
+	}
+
+	// curl removes header with empty value, so it must be sent with semicolon
+	v = strings.Trim(v, " \t")
+	if v == "" {
+		return k + ";", nil
+	}
+
+	return k + ": " + v, nil
+}
+
+// ValidHeaderName reports if name of header is a token of RFC 9110
+func ValidHeaderName(k string) bool {
+	if k == "" {
+		return false
+	}
+
+	for i := 0; i < len(k); i++ {
+		c := k[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidHeaderValue reports if value of header has no CR, LF or NUL, that could split it to few headers
+func ValidHeaderValue(v string) bool {
+	return !strings.ContainsAny(v, "\r\n\x00")
+}
+
+// Generate returns arguments for curl, invalid headers are skipped, use Args to get error for them
+func (h *Headers) Generate(shuffle bool) []string {
+	args, _ := h.args(shuffle, false)
+	return CommandLine(args)
+}
+
+// MergeHeaders returns base headers with headers of override. Headers of base are replaced in place and keep case of name, new headers are inserted by order of names (e.g. order of browser), unknown headers are added at the end.
+func MergeHeaders(base *Headers, override *Headers, order []string) *Headers {
+	result := base.Clone()
+	if override == nil {
+		return result
+	}
+
+	rank := func(k string) int {
+		for i, name := range order {
+			if strings.EqualFold(name, k) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for _, k := range override.Keys() {
+		var values []header
+		for _, e := range override.list {
+			if strings.EqualFold(e.name, k) {
+				values = append(values, e)
+			}
 		}
-	} else {
-		for _, k := range h.keys {
-			result = append(result, "-H", fmt.Sprintf("'%s: %s'", k, h.m[k]))
+
+		pos := -1
+		for i, e := range result.list {
+			if strings.EqualFold(e.name, k) {
+				pos = i
+				// keep case of base, e.g. lower case of sec-ch-ua
+				for j := range values {
+					values[j].name = e.name
+				}
+				break
+			}
 		}
+
+		if pos != -1 {
+			result.Del(k)
+		} else {
+			pos = len(result.list)
+			if r := rank(k); r != -1 {
+				last := -1
+				for i, e := range result.list {
+					if er := rank(e.name); er > r {
+						pos = i
+						break
+					} else if er != -1 {
+						last = i
+					}
+				}
+				if pos == len(result.list) && last != -1 {
+					pos = last + 1
+				}
+			}
+		}
+
+		list := append([]header(nil), result.list[:pos]...)
+		list = append(list, values...)
+		result.list = append(list, result.list[pos:]...)
 	}
+
 	return result
 }
@@ -0,0 +1,113 @@
+package types
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestHeaders(t *testing.T) {
+	h := NewHeaders(
+		Header("User-Agent", "agent"),
+		Header("Cookie", "a=1"),
+		Header("Accept", "*/*"),
+	)
+
+	h.Set("user-agent", "other")
+	h.Add("cookie", "b=2")
+	h.Add("X-Test", "1")
+
+	if got := h.Keys(); !reflect.DeepEqual(got, []string{"User-Agent", "Cookie", "Accept", "X-Test"}) {
+		t.Errorf("Unexpected keys: %v", got)
+	}
+
+	if got := h.Get("USER-AGENT"); got != "other" {
+		t.Errorf("Unexpected value: %s", got)
+	}
+
+	if got := h.Values("COOKIE"); !reflect.DeepEqual(got, []string{"a=1", "b=2"}) {
+		t.Errorf("Unexpected values: %v", got)
+	}
+
+	if !h.Has("x-test") || h.Has("X-Missing") || h.Len() != 5 {
+		t.Errorf("Unexpected Has or Len: %d", h.Len())
+	}
+
+	var names []string
+	h.Range(func(k string, v string) bool {
+		names = append(names, k+": "+v)
+		return true
+	})
+	if !reflect.DeepEqual(names, []string{"User-Agent: other", "Cookie: a=1", "Accept: */*", "cookie: b=2", "X-Test: 1"}) {
+		t.Errorf("Unexpected order: %v", names)
+	}
+
+	h.Del("cookie")
+	h.Remove("accept")
+	if h.Has("Cookie") || h.Has("Accept") || !h.IsRemoved("Accept") || h.Len() != 2 {
+		t.Errorf("Unexpected headers after Del and Remove: %v", h.Keys())
+	}
+
+	if args := h.Generate(false); !reflect.DeepEqual(args, []string{"--header", "User-Agent: other", "--header", "Accept:", "--header", "X-Test: 1"}) {
+		t.Errorf("Unexpected arguments: %q", args)
+	}
+}
+
+func TestHTTPHeader(t *testing.T) {
+	src := http.Header{
+		"Cookie": {"a=1", "b=2"},
+		"Accept": {"*/*"},
+	}
+
+	h := FromHTTPHeader(src)
+	if got := h.Keys(); !reflect.DeepEqual(got, []string{"Accept", "Cookie"}) {
+		t.Errorf("Unexpected keys: %v", got)
+	}
+
+	h.Remove("Accept")
+	h.Add("x-test", "1")
+	expected := http.Header{
+		"Cookie": {"a=1", "b=2"},
+		"X-Test": {"1"},
+	}
+	if got := h.HTTPHeader(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected http.Header: %v", got)
+	}
+}
+
+func TestMergeHeaders(t *testing.T) {
+	base := NewHeaders(
+		Header("sec-ch-ua", "brand"),
+		Header("User-Agent", "agent"),
+		Header("Accept", "*/*"),
+		Header("Accept-Language", "en"),
+	)
+	override := NewHeaders(
+		Header("X-Custom", "1"),
+		Header("accept", "text/html"),
+		Header("SEC-CH-UA", "other"),
+		Header("Cookie", "a=1"),
+		Header("Referer", "http://localhost/"),
+		RemoveHeader("Accept-Language"),
+	)
+	order := []string{"sec-ch-ua", "User-Agent", "Accept", "Referer", "Accept-Language", "Cookie"}
+
+	args := MergeHeaders(base, override, order).Generate(false)
+
+	expected := []string{
+		"--header", "sec-ch-ua: other",
+		"--header", "User-Agent: agent",
+		"--header", "Accept: text/html",
+		"--header", "Referer: http://localhost/",
+		"--header", "Accept-Language:",
+		"--header", "Cookie: a=1",
+		"--header", "X-Custom: 1",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("Unexpected headers\nExpected: %q\nGot:      %q", expected, args)
+	}
+
+	if base.Get("Accept") != "*/*" {
+		t.Errorf("Expected base to be unchanged")
+	}
+}